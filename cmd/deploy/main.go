@@ -1,13 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"os/user"
+	"syscall"
 
 	"github.com/dadyutenga/git-engine/internal/application"
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"github.com/dadyutenga/git-engine/internal/infrastructure/artifact"
+	"github.com/dadyutenga/git-engine/internal/infrastructure/backup"
+	"github.com/dadyutenga/git-engine/internal/infrastructure/database"
 	"github.com/dadyutenga/git-engine/internal/infrastructure/detectors"
+	"github.com/dadyutenga/git-engine/internal/infrastructure/health"
+	"github.com/dadyutenga/git-engine/internal/infrastructure/local"
 	"github.com/dadyutenga/git-engine/internal/infrastructure/logger"
+	"github.com/dadyutenga/git-engine/internal/infrastructure/manifest"
 	"github.com/dadyutenga/git-engine/internal/infrastructure/remote"
+	"github.com/dadyutenga/git-engine/internal/infrastructure/source"
 	"github.com/dadyutenga/git-engine/internal/infrastructure/ssh"
 	"github.com/dadyutenga/git-engine/internal/interfaces/cli"
 )
@@ -17,48 +30,130 @@ func main() {
 	if configPath == "" {
 		configPath = "configs/config.yaml"
 	}
+	manifestPath := os.Getenv("DEPLOY_MANIFEST")
+	if manifestPath == "" {
+		manifestPath = manifest.FileName
+	}
 
+	// The agent runs on the server from the post-receive hook, usually
+	// without a CLI config.
+	agent := len(os.Args) > 1 && os.Args[1] == "agent"
 	cfg, err := cli.LoadConfig(configPath)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
-		os.Exit(1)
+	if agent && errors.Is(err, os.ErrNotExist) {
+		cfg, err = cli.Config{}, nil
 	}
-
-	client, err := ssh.New(cfg.SSH)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to connect via ssh: %v\n", err)
+		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
 		os.Exit(1)
 	}
-	defer func() {
-		if err := client.Close(); err != nil {
-			fmt.Fprintf(os.Stderr, "failed to close ssh client: %v\n", err)
-		}
-	}()
-
-	exec := remote.Executor{Client: client}
-	fs := remote.FileSystem{Exec: exec}
-	lockManager := remote.LockManager{Exec: exec}
-
-	strategies := []application.DeploymentStrategy{
-		detectors.DockerStrategy{Exec: exec, FS: fs},
-		detectors.NodeStrategy{},
-		detectors.LaravelStrategy{Exec: exec},
-		detectors.PythonStrategy{Exec: exec},
-		detectors.StaticStrategy{},
-	}
 
 	log := logger.New(os.Stdout)
 	app := cli.CLI{
-		InitService:     application.InitService{Exec: exec, FS: fs},
-		DeployService:   application.DeployService{Exec: exec, FS: fs, Lock: lockManager, Strategies: strategies, Branch: "main"},
-		RollbackService: application.RollbackService{Exec: exec, FS: fs, Strategies: strategies},
-		StatusService:   application.StatusService{Exec: exec, FS: fs, Strategies: strategies},
-		LogsService:     application.LogsService{Exec: exec},
-		Logger:          log,
+		Connect: func(target cli.Target, opts cli.ConnectOptions) (cli.Session, error) {
+			return connect(cfg, target, opts)
+		},
+		Agent:        agentSession,
+		Logger:       log,
+		Config:       cfg,
+		ManifestPath: manifestPath,
 	}
 
-	if err := app.Run(os.Args[1:]); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := app.Run(ctx, os.Args[1:]); err != nil {
+		stop()
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// connect dials the target host and wires the application services to it.
+func connect(cfg cli.Config, target cli.Target, opts cli.ConnectOptions) (cli.Session, error) {
+	client, err := ssh.New(target.SSH)
+	if err != nil {
+		return cli.Session{}, fmt.Errorf("failed to connect via ssh: %w", err)
+	}
+	if _, err := client.SFTP(); err != nil {
+		client.Close()
+		return cli.Session{}, err
+	}
+
+	var exec application.RemoteExecutor = remote.Executor{Client: client}
+	var localExec application.RemoteExecutor = local.Executor{}
+	var fs application.RemoteFileSystem = remote.SFTPFileSystem{Client: client}
+	var lockManager application.LockManager = remote.LockManager{Exec: exec}
+	var checker application.HealthChecker = health.Checker{}
+	var builder application.ArtifactBuilder = artifact.Builder{Local: localExec}
+	var archives application.LocalArchives = backup.Archives{}
+	var plan *remote.Plan
+	if opts.DryRun {
+		plan = &remote.Plan{}
+		exec = remote.DryRunExecutor{Plan: plan, Probe: exec, Label: "remote", Aliases: []remote.Alias{remote.ReleaseSourceAlias}}
+		fs = remote.DryRunFileSystem{Plan: plan, Probe: fs, Aliases: []remote.Alias{remote.ReleaseSourceAlias}}
+		localExec = remote.DryRunExecutor{Plan: plan, Label: "local"}
+		lockManager = remote.DryRunLock{Plan: plan}
+		checker = health.Planned{Plan: plan}
+		builder = artifact.Planned{Plan: plan}
+		archives = backup.PlannedArchives{Plan: plan}
+	}
+	var pusher application.SourcePusher
+	if !opts.SkipPush {
+		pusher = source.Pusher{SSH: target.SSH}
+	}
+
+	strategies := newStrategies(fs)
+	stores := newBackupStores(fs)
+
+	return cli.Session{
+		Init:     application.InitService{Exec: exec, FS: fs, Agent: cfg.AgentPath, Actor: actor()},
+		Deploy:   application.DeployService{Exec: exec, FS: fs, Lock: lockManager, Strategies: strategies, Health: checker, Local: localExec, Builder: builder, Source: pusher, Actor: actor(), BackupStores: stores, Dumper: database.Dumper{FS: fs}},
+		Rollback: application.RollbackService{Exec: exec, FS: fs, Lock: lockManager, Strategies: strategies, Local: localExec, Actor: actor(), BackupStores: stores, Dumper: database.Dumper{FS: fs}},
+		Status:   application.StatusService{Exec: exec, FS: fs, Strategies: strategies},
+		Logs:     application.LogsService{Exec: exec},
+		History:  application.HistoryService{FS: fs},
+		Backups:  application.BackupService{Exec: exec, FS: fs, Stores: stores, Local: archives},
+		Plan:     plan,
+		Close:    client.Close,
+	}, nil
+}
+
+// agentSession wires the deploy services to the local machine for
+// "deploy agent run", echoing every command and its output so the git push
+// client can follow.
+func agentSession() (cli.Session, error) {
+	exec := local.Executor{Trace: os.Stdout}
+	fs := local.FileSystem{}
+
+	strategies := newStrategies(fs)
+
+	return cli.Session{
+		Deploy: application.DeployService{Exec: exec, FS: fs, Lock: remote.LockManager{Exec: exec}, Strategies: strategies, Health: health.Checker{}, Local: exec, Actor: actor(), BackupStores: newBackupStores(fs), Dumper: database.Dumper{FS: fs}},
+	}, nil
+}
+
+// actor identifies the local user and machine in the history ledger.
+func actor() domain.Actor {
+	var a domain.Actor
+	if u, err := user.Current(); err == nil {
+		a.User = u.Username
+	}
+	a.Hostname, _ = os.Hostname()
+	return a
+}
+
+func newStrategies(fs application.RemoteFileSystem) []application.DeploymentStrategy {
+	return []application.DeploymentStrategy{
+		detectors.DockerStrategy{FS: fs},
+		detectors.NodeStrategy{},
+		detectors.LaravelStrategy{},
+		detectors.PythonStrategy{},
+		detectors.StaticStrategy{},
+	}
+}
+
+func newBackupStores(fs application.RemoteFileSystem) []application.BackupStore {
+	return []application.BackupStore{
+		backup.TarballStore{FS: fs},
+		backup.SnapshotStore{FS: fs},
+	}
+}
@@ -0,0 +1,286 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"github.com/dadyutenga/git-engine/internal/shared/shell"
+)
+
+// BackupService inspects, prunes and transfers the release backups of a
+// project.
+type BackupService struct {
+	Exec RemoteExecutor
+	FS   RemoteFileSystem
+	// Stores are searched for backups; a project may have backups in several
+	// after switching stores.
+	Stores []BackupStore
+	// Local keeps the copies pulled from or pushed to the server.
+	Local LocalArchives
+}
+
+// List returns the backups of project, newest first, described by their
+// manifests. For archives without a manifest the release and commit they
+// captured are looked up in the history ledger.
+func (s BackupService) List(ctx context.Context, project domain.Project) ([]domain.Backup, error) {
+	backups, err := listBackups(ctx, s.Stores, project)
+	if err != nil {
+		return nil, err
+	}
+	var entries []domain.HistoryEntry
+	for i, b := range backups {
+		if b.HasManifest {
+			continue
+		}
+		if entries == nil {
+			if entries, err = (HistoryService{FS: s.FS}).List(ctx, project, domain.HistoryFilter{}); err != nil {
+				return nil, err
+			}
+		}
+		for _, e := range entries {
+			if e.Operation == domain.OperationPush && e.Backup == b.Name {
+				backups[i].Release = e.PreviousRelease
+			}
+		}
+		deployed := releaseEntry(entries, backups[i].Release)
+		backups[i].Commit, backups[i].Strategy = deployed.Commit, deployed.Strategy
+	}
+	return backups, nil
+}
+
+// Show describes the backup called name.
+func (s BackupService) Show(ctx context.Context, project domain.Project, name string) (domain.Backup, error) {
+	backups, err := s.List(ctx, project)
+	if err != nil {
+		return domain.Backup{}, err
+	}
+	for _, b := range backups {
+		if b.Name == name {
+			return b, nil
+		}
+	}
+	return domain.Backup{}, fmt.Errorf("%w: %s", domain.ErrBackupNotFound, name)
+}
+
+// Delete removes the backup called name.
+func (s BackupService) Delete(ctx context.Context, project domain.Project, name string) error {
+	b, err := s.Show(ctx, project, name)
+	if err != nil {
+		return err
+	}
+	_, err = removeBackups(ctx, s.Stores, s.Exec, project, []domain.Backup{b})
+	return err
+}
+
+// Prune removes the backups outside the project's retention policy and
+// returns them.
+func (s BackupService) Prune(ctx context.Context, project domain.Project) ([]domain.Backup, error) {
+	backups, err := listBackups(ctx, s.Stores, project)
+	if err != nil {
+		return nil, err
+	}
+	return removeBackups(ctx, s.Stores, s.Exec, project, project.Backups.Retention.Expired(backups, time.Now()))
+}
+
+// Pull downloads the backup called name, or the newest one when name is
+// empty, into dir after checking it against its manifest on the server. It
+// returns the manifest of the local copy and its path.
+func (s BackupService) Pull(ctx context.Context, project domain.Project, name, dir string) (domain.Backup, string, error) {
+	backups, err := s.List(ctx, project)
+	if err != nil {
+		return domain.Backup{}, "", err
+	}
+	i := slices.IndexFunc(backups, func(b domain.Backup) bool { return name == "" || b.Name == name })
+	switch {
+	case i < 0 && name == "":
+		return domain.Backup{}, "", fmt.Errorf("%w: %s has no backups", domain.ErrBackupNotFound, project.Name)
+	case i < 0:
+		return domain.Backup{}, "", fmt.Errorf("%w: %s", domain.ErrBackupNotFound, name)
+	}
+	b := backups[i]
+	store, err := backupStore(s.Stores, b.Store)
+	if err != nil {
+		return b, "", err
+	}
+	if b.HasManifest {
+		if err := store.Verify(ctx, project, s.Exec, b); err != nil {
+			return b, "", err
+		}
+	} else {
+		log.Printf("WARNING: backup %s of %s has no manifest and cannot be verified", b.Name, project.Name)
+	}
+
+	archive, exported, err := store.Export(ctx, project, s.Exec, b)
+	if err != nil {
+		return b, "", err
+	}
+	if archive != filepath.Join(project.BackupDir, b.Name) {
+		defer func() {
+			if _, err := s.Exec.Run(context.WithoutCancel(ctx), "rm -f "+shell.Escape(archive)); err != nil {
+				log.Printf("WARNING: failed to remove exported archive %s: %v", archive, err)
+			}
+		}()
+	}
+	path, err := s.Local.Fetch(s.FS, archive, exported, dir)
+	return exported, path, err
+}
+
+// Push uploads the local archive at path, checked against the manifest next
+// to it, and adds it to the project's backup store.
+func (s BackupService) Push(ctx context.Context, project domain.Project, path string) (domain.Backup, error) {
+	b, err := s.Local.Open(path)
+	if err != nil {
+		return domain.Backup{}, err
+	}
+	// Database dumps stay on the server they were taken on.
+	b.Database = nil
+	if !b.HasManifest {
+		log.Printf("WARNING: %s has no manifest; only the upload itself is verified", path)
+	}
+	store, err := backupStore(s.Stores, project.Backups.Store)
+	if err != nil {
+		return b, err
+	}
+	if err := s.FS.Mkdir(project.BackupDir, true); err != nil {
+		return b, fmt.Errorf("ensure backup directory: %w", err)
+	}
+	upload := filepath.Join(project.BaseDir, ".upload-"+b.Name)
+	if err := s.FS.Upload(path, upload); err != nil {
+		_ = s.FS.Remove(upload)
+		return b, fmt.Errorf("upload %s: %w", path, err)
+	}
+	imported, err := store.Import(ctx, project, s.Exec, b, upload)
+	if err != nil {
+		_ = s.FS.Remove(upload)
+	}
+	return imported, err
+}
+
+// backupStore returns the store named name.
+func backupStore(stores []BackupStore, name string) (BackupStore, error) {
+	for _, store := range stores {
+		if store.Name() == name {
+			return store, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown backup store %q", name)
+}
+
+// listBackups returns the backups of every store, newest first.
+func listBackups(ctx context.Context, stores []BackupStore, project domain.Project) ([]domain.Backup, error) {
+	var backups []domain.Backup
+	for _, store := range stores {
+		held, err := store.List(ctx, project)
+		if err != nil {
+			return nil, fmt.Errorf("list %s backups: %w", store.Name(), err)
+		}
+		backups = append(backups, held...)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].CreatedAt.Equal(backups[j].CreatedAt) {
+			return backups[i].CreatedAt.After(backups[j].CreatedAt)
+		}
+		return backups[i].Name > backups[j].Name
+	})
+	return backups, nil
+}
+
+// pruneBackups enforces the project's retention policy after a deployment.
+func pruneBackups(ctx context.Context, stores []BackupStore, exec RemoteExecutor, project domain.Project, now time.Time) ([]domain.Backup, error) {
+	backups, err := listBackups(ctx, stores, project)
+	if err != nil {
+		return nil, err
+	}
+	return removeBackups(ctx, stores, exec, project, project.Backups.Retention.Expired(backups, now))
+}
+
+// removeBackups deletes backups, with their database dumps, from their stores
+// and returns the ones that were removed.
+func removeBackups(ctx context.Context, stores []BackupStore, exec RemoteExecutor, project domain.Project, backups []domain.Backup) ([]domain.Backup, error) {
+	for i, b := range backups {
+		store, err := backupStore(stores, b.Store)
+		if err == nil {
+			err = store.Remove(ctx, project, exec, b)
+		}
+		if err == nil && b.Database != nil {
+			_, err = exec.Run(ctx, "rm -f "+shell.Escape(filepath.Join(project.BackupDir, b.Database.File)))
+		}
+		if err != nil {
+			return backups[:i], err
+		}
+	}
+	return backups, nil
+}
+
+// releaseEntry returns the last history entry that recorded the commit of
+// release, which holds its ref, commit and strategy. It is zero when the
+// history does not know the release.
+func releaseEntry(entries []domain.HistoryEntry, release string) domain.HistoryEntry {
+	var deployed domain.HistoryEntry
+	if release == "" {
+		return deployed
+	}
+	for _, e := range entries {
+		if e.Release == release && e.Commit != "" {
+			deployed = e
+		}
+	}
+	return deployed
+}
+
+// createBackup backs up b.Release, taken at b.CreatedAt, in the project's
+// backup store. Paths the release's strategy rebuilds are left out unless the
+// project includes them again.
+func createBackup(ctx context.Context, stores []BackupStore, exec RemoteExecutor, fs RemoteFileSystem, strategies []DeploymentStrategy, project domain.Project, b domain.Backup) (domain.Backup, error) {
+	store, err := backupStore(stores, project.Backups.Store)
+	if err != nil {
+		return domain.Backup{}, err
+	}
+	if err := fs.Mkdir(project.BackupDir, true); err != nil {
+		return domain.Backup{}, fmt.Errorf("ensure backup directory: %w", err)
+	}
+	release := b.Release
+	b.Compression = project.Backups.Compression
+	var defaults []string
+	if strategy := detectStrategy(ctx, strategies, fs, exec, project.WithRelease(release)); strategy != nil {
+		b.Strategy, defaults = strategy.Name(), strategy.BackupExcludes()
+	}
+	b.Excludes = project.Backups.Excludes(defaults)
+
+	entries, err := HistoryService{FS: fs}.List(ctx, project, domain.HistoryFilter{})
+	if err != nil {
+		log.Printf("WARNING: failed to read the history of %s for the backup manifest: %v", project.Name, err)
+	}
+	deployed := releaseEntry(entries, release)
+	b.Commit = deployed.Commit
+	if deployed.Strategy != "" {
+		b.Strategy = deployed.Strategy
+	}
+	return store.Create(ctx, project, exec, b)
+}
+
+// verifyBackup finds name among the project's backups and checks that it
+// still matches its manifest.
+func verifyBackup(ctx context.Context, stores []BackupStore, exec RemoteExecutor, project domain.Project, name string) (domain.Backup, BackupStore, error) {
+	backups, err := listBackups(ctx, stores, project)
+	if err != nil {
+		return domain.Backup{}, nil, err
+	}
+	for _, b := range backups {
+		if b.Name != name {
+			continue
+		}
+		store, err := backupStore(stores, b.Store)
+		if err != nil || !b.HasManifest {
+			return b, store, err
+		}
+		return b, store, store.Verify(ctx, project, exec, b)
+	}
+	return domain.Backup{}, nil, fmt.Errorf("%w: %s", domain.ErrBackupNotFound, name)
+}
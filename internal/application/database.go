@@ -0,0 +1,75 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"github.com/dadyutenga/git-engine/internal/shared/shell"
+)
+
+// projectDatabase reads the database settings from the .env of the project's
+// release.
+func projectDatabase(fs RemoteFileSystem, project domain.Project) (domain.Database, error) {
+	path := filepath.Join(project.DeployDir, ".env")
+	data, err := fs.ReadFile(path)
+	if err != nil {
+		return domain.Database{}, fmt.Errorf("read %s: %w", path, err)
+	}
+	db, err := domain.DatabaseFromEnv(domain.ParseEnvFile(data), project.DeployDir)
+	if err != nil {
+		return domain.Database{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
+
+// dumpDatabase dumps the database of release into the backup directory, named
+// after the backup taken at now.
+func dumpDatabase(ctx context.Context, dumper DatabaseDumper, exec RemoteExecutor, fs RemoteFileSystem, project domain.Project, release string, now time.Time) (domain.DatabaseDump, error) {
+	if dumper == nil {
+		return domain.DatabaseDump{}, fmt.Errorf("database backups configured for %s but no database dumper available", project.Name)
+	}
+	db, err := projectDatabase(fs, project.WithRelease(release))
+	if err != nil {
+		return domain.DatabaseDump{}, err
+	}
+	if err := fs.Mkdir(project.BackupDir, true); err != nil {
+		return domain.DatabaseDump{}, fmt.Errorf("ensure backup directory: %w", err)
+	}
+	path := filepath.Join(project.BackupDir, fmt.Sprintf("%s-%d.%s.gz", project.Name, now.Unix(), db.Engine))
+	return dumper.Dump(ctx, exec, db, path)
+}
+
+// restoreDatabase checks a dump against its checksum and loads it into the
+// database configured for the project's release.
+func restoreDatabase(ctx context.Context, dumper DatabaseDumper, exec RemoteExecutor, fs RemoteFileSystem, project domain.Project, dump domain.DatabaseDump) error {
+	if dumper == nil {
+		return fmt.Errorf("no database dumper available for %s", project.Name)
+	}
+	db, err := projectDatabase(fs, project)
+	if err != nil {
+		return err
+	}
+	if db.Engine != dump.Engine {
+		return fmt.Errorf("cannot restore a %s dump into the %s database of %s", dump.Engine, db.Engine, project.Name)
+	}
+	path := filepath.Join(project.BackupDir, dump.File)
+	if _, err := exec.Run(Retryable(ctx), fmt.Sprintf("echo %s | sha256sum -c --status", shell.Escape(dump.SHA256+"  "+path))); err != nil {
+		if domain.ExitStatus(err) == 1 {
+			return fmt.Errorf("%w: %s does not match its manifest", domain.ErrChecksumMismatch, dump.File)
+		}
+		return err
+	}
+	return dumper.Restore(ctx, exec, db, path)
+}
+
+// removeDump deletes a dump whose backup could not be taken.
+func removeDump(ctx context.Context, exec RemoteExecutor, project domain.Project, dump domain.DatabaseDump) {
+	path := filepath.Join(project.BackupDir, dump.File)
+	if _, err := exec.Run(context.WithoutCancel(ctx), "rm -f "+shell.Escape(path)); err != nil {
+		log.Printf("WARNING: failed to remove database dump %s: %v", path, err)
+	}
+}
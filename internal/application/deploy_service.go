@@ -1,6 +1,7 @@
 package application
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"time"
@@ -15,16 +16,41 @@ type DeployService struct {
 	FS         RemoteFileSystem
 	Lock       LockManager
 	Strategies []DeploymentStrategy
-	Branch     string
+	Health     HealthChecker
+	// Local runs hooks declared with local: true.
+	Local RemoteExecutor
+	// Builder builds projects in artifact mode; nil disables artifact mode.
+	Builder ArtifactBuilder
+	// Source pushes the local repository before the checkout is updated; nil
+	// deploys whatever the bare repository already holds.
+	Source SourcePusher
+	// Actor is recorded in the history ledger as the initiator.
+	Actor domain.Actor
+	// BackupStores hold the backups; the project selects the one new backups
+	// go to.
+	BackupStores []BackupStore
+	// Dumper dumps the database with each backup when the project asks for it.
+	Dumper DatabaseDumper
+
+	// transcript records the running deployment in the project log file.
+	transcript *transcript
 }
 
 // Deploy executes a deployment pipeline for the given project.
-func (s DeployService) Deploy(projectName string) (domain.DeploymentResult, error) {
-	project := domain.NewProject(projectName)
+//
+// Sources are exported into a fresh release directory and the strategy builds
+// there; the current symlink is only switched once the build succeeds, and
+// the services are only restarted after the switch, so a failed build never
+// affects the live release. A failed restart restores the previous release.
+//
+// Once the release is live its health checks are run; if they keep failing the
+// previous release is restored and the result reports the "rolled_back" status.
+// Every deployment that took the lock is appended to the history ledger.
+func (s DeployService) Deploy(ctx context.Context, project domain.Project) (domain.DeploymentResult, error) {
 	now := time.Now()
-	result := domain.DeploymentResult{ProjectName: project.Name, Timestamp: now, LogFile: project.LogFile}
+	result := domain.DeploymentResult{ID: domain.NewDeploymentID(now), ProjectName: project.Name, Timestamp: now, LogFile: project.LogFile, Details: map[string]string{}}
 
-	exists, err := s.FS.Exists(project.DeployDir)
+	exists, err := s.FS.Exists(project.BaseDir)
 	if err != nil {
 		result.Message = "failed to check project path"
 		return result, err
@@ -34,7 +60,7 @@ func (s DeployService) Deploy(projectName string) (domain.DeploymentResult, erro
 		return result, domain.ErrProjectNotFound
 	}
 
-	acquired, err := s.Lock.Acquire(project)
+	acquired, err := s.Lock.Acquire(ctx, project)
 	if err != nil {
 		result.Message = "failed to acquire deployment lock"
 		return result, err
@@ -44,54 +70,305 @@ func (s DeployService) Deploy(projectName string) (domain.DeploymentResult, erro
 		return result, domain.ErrLockUnavailable
 	}
 	defer func() {
-		if err := s.Lock.Release(project); err != nil {
+		if err := s.Lock.Release(context.WithoutCancel(ctx), project); err != nil {
 			log.Printf("WARNING: failed to release lock for %s: %v", project.Name, err)
 		}
 	}()
 
-	if err := s.FS.Mkdir(project.BackupDir, true); err != nil {
-		result.Message = "failed to ensure backup directory"
-		return result, err
-	}
+	s.transcript = newTranscript(s.FS, project, result.ID)
+	s.transcript.start(domain.OperationPush, s.Actor, project.Env)
+	s.Exec = s.transcript.executor(s.Exec, "")
+	s.Local = s.transcript.executor(s.Local, "local")
 
-	backupName := fmt.Sprintf("%s/%s-%d.tgz", project.BackupDir, project.Name, now.Unix())
-	if _, err := s.Exec.Run(fmt.Sprintf("tar -czf %s -C %s .", shell.Escape(backupName), shell.Escape(project.DeployDir))); err != nil {
-		result.Message = "failed to create backup"
-		return result, err
+	result, err = s.deploy(ctx, project, now, result)
+	if err != nil {
+		hooks, _ := s.hooks().run(context.WithoutCancel(ctx), project, domain.HookOnFailure, project.BaseDir)
+		result.Hooks = append(result.Hooks, hooks...)
 	}
+	entry := s.historyEntry(result, now, err)
+	s.transcript.finish(domain.OperationPush, entry.Outcome, entry.Message, err)
+	recordHistory(s.FS, project, entry)
+	return result, err
+}
 
-	branch := s.Branch
-	if branch == "" {
-		branch = "main"
+// historyEntry describes a finished deployment for the history ledger.
+func (s DeployService) historyEntry(result domain.DeploymentResult, started time.Time, err error) domain.HistoryEntry {
+	entry := domain.HistoryEntry{
+		ID:              result.ID,
+		Operation:       domain.OperationPush,
+		Project:         result.ProjectName,
+		User:            s.Actor.User,
+		Hostname:        s.Actor.Hostname,
+		Ref:             result.Details["ref"],
+		Commit:          result.Details["commit"],
+		Release:         result.Details["release"],
+		PreviousRelease: result.Details["previous_release"],
+		Strategy:        result.Details["strategy"],
+		Backup:          result.Details["backup"],
+		StartedAt:       started.UTC(),
+		DurationMS:      time.Since(started).Milliseconds(),
+		Outcome:         domain.OutcomeSuccess,
+		Message:         result.Message,
 	}
-	if _, err := s.Exec.Run(fmt.Sprintf("cd %s && git fetch origin %s && git reset --hard origin/%s", shell.Escape(project.DeployDir), shell.Escape(branch), shell.Escape(branch))); err != nil {
-		result.Message = "failed to update sources"
+	switch {
+	case result.Status == "rolled_back":
+		entry.Outcome = domain.OutcomeRolledBack
+	case !result.Success:
+		entry.Outcome = domain.OutcomeFailed
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+func (s DeployService) deploy(ctx context.Context, project domain.Project, now time.Time, result domain.DeploymentResult) (domain.DeploymentResult, error) {
+	previous, err := currentRelease(ctx, s.Exec, project)
+	if err != nil {
+		result.Message = "failed to resolve current release"
 		return result, err
 	}
+	if previous != "" {
+		result.Details["previous_release"] = previous
 
-	var strategy DeploymentStrategy
-	for _, st := range s.Strategies {
-		ok, derr := st.Detect(s.FS, project)
-		if derr != nil {
-			continue
+		backup := domain.Backup{Release: previous, CreatedAt: now.UTC()}
+		if project.Backups.Database {
+			dump, err := dumpDatabase(ctx, s.Dumper, s.Exec, s.FS, project, previous, now)
+			if err != nil {
+				result.Message = "failed to dump database"
+				return result, err
+			}
+			s.transcript.step("dumped %s database to %s (%d bytes, sha256 %s)", dump.Engine, dump.File, dump.Size, dump.SHA256)
+			backup.Database = &dump
 		}
-		if ok {
-			strategy = st
-			break
+		created, err := createBackup(ctx, s.BackupStores, s.Exec, s.FS, s.Strategies, project, backup)
+		if err != nil {
+			if backup.Database != nil {
+				removeDump(ctx, s.Exec, project, *backup.Database)
+			}
+			result.Message = "failed to create backup"
+			return result, err
 		}
+		backup = created
+		s.transcript.step("backed up release %s to %s (%d bytes, sha256 %s)", previous, backup.Name, backup.Size, backup.SHA256)
+		result.Details["backup"] = backup.Name
 	}
+
+	release := domain.NewReleaseID(now)
+	target := project.WithRelease(release)
+	result.Details["ref"] = project.Revision()
+
+	var commit, message string
+	if project.Artifact != nil {
+		result.Details["mode"] = "artifact"
+		commit, message, err = s.installArtifact(ctx, project, target, result.Details)
+	} else {
+		commit, message, err = s.exportSources(ctx, project, target)
+	}
+	if err != nil {
+		result.Message = message
+		return result, err
+	}
+	result.Details["commit"] = commit
+	result.Details["release"] = release
+
+	if err := linkShared(ctx, s.Exec, target); err != nil {
+		s.discardRelease(ctx, target)
+		result.Message = "failed to link shared files"
+		return result, err
+	}
+
+	strategy := detectStrategy(ctx, s.Strategies, s.FS, s.Exec, target)
 	if strategy == nil {
+		s.discardRelease(ctx, target)
 		result.Message = "unsupported project type"
+		if project.Strategy != "" {
+			result.Message = fmt.Sprintf("unknown strategy %q", project.Strategy)
+		}
 		return result, domain.ErrUnsupportedProject
 	}
+	result.Details["strategy"] = strategy.Name()
 
-	if err := strategy.Deploy(project, s.Exec); err != nil {
-		result.Message = fmt.Sprintf("%s deployment failed", strategy.Name())
+	hooks, err := s.hooks().run(ctx, target, domain.HookPreDeploy, target.DeployDir)
+	result.Hooks = append(result.Hooks, hooks...)
+	if err != nil {
+		s.discardRelease(ctx, target)
+		result.Message = "pre-deploy hook failed"
 		return result, err
 	}
 
+	// Artifacts arrive built, so only the restart part of the strategy runs.
+	// The services keep running the live release until after the switch.
+	if project.Artifact == nil {
+		if err := strategy.Deploy(ctx, target, withEnv(s.Exec, project.Env)); err != nil {
+			s.discardRelease(ctx, target)
+			result.Message = fmt.Sprintf("%s deployment failed", strategy.Name())
+			return result, err
+		}
+	}
+
+	if err := activateRelease(s.FS, project, release); err != nil {
+		s.discardRelease(ctx, target)
+		result.Message = "failed to activate release"
+		return result, err
+	}
+	s.transcript.step("activated release %s", release)
+
+	if err := strategy.Restart(ctx, project, withEnv(s.Exec, project.Env)); err != nil {
+		return s.revert(ctx, result, project, previous, release, fmt.Sprintf("failed to start with the %s strategy", strategy.Name()), err)
+	}
+
+	if err := s.checkHealth(ctx, project); err != nil {
+		return s.revert(ctx, result, project, previous, release, "failed health checks", err)
+	}
+
+	hooks, err = s.hooks().run(ctx, project, domain.HookPostDeploy, project.DeployDir)
+	result.Hooks = append(result.Hooks, hooks...)
+	if err != nil {
+		return s.revert(ctx, result, project, previous, release, "failed its post-deploy hooks", err)
+	}
+
+	if err := pruneReleases(ctx, s.Exec, s.FS, project, releasesToKeep); err != nil {
+		log.Printf("WARNING: failed to prune old releases for %s: %v", project.Name, err)
+	}
+	pruned, err := pruneBackups(ctx, s.BackupStores, s.Exec, project, now)
+	for _, b := range pruned {
+		s.transcript.step("removed backup %s", b.Name)
+	}
+	if err != nil {
+		log.Printf("WARNING: failed to prune old backups for %s: %v", project.Name, err)
+	}
+
 	result.Success = true
 	result.Status = "deployed"
-	result.Message = fmt.Sprintf("%s deployed with %s strategy", project.Name, strategy.Name())
+	result.Message = fmt.Sprintf("%s deployed %s (%s) with %s strategy (release %s)", project.Name, project.Revision(), shortCommit(commit), strategy.Name(), release)
 	return result, nil
 }
+
+// exportSources pushes and checks out the requested revision on the server and
+// exports it into the release directory. It returns the deployed commit, or a
+// failure message with the error.
+func (s DeployService) exportSources(ctx context.Context, project, target domain.Project) (string, string, error) {
+	if s.Source != nil {
+		if err := s.Source.Push(ctx, project, s.Local); err != nil {
+			return "", "failed to push sources", err
+		}
+		s.transcript.step("pushed %s to %s", project.Branch, project.RepoPath)
+	}
+	commit, err := checkoutRevision(ctx, s.Exec, project)
+	if err != nil {
+		return "", fmt.Sprintf("failed to check out %s", project.Revision()), err
+	}
+	if err := s.FS.Mkdir(target.DeployDir, true); err != nil {
+		return "", "failed to create release directory", err
+	}
+	if _, err := s.Exec.Run(ctx, fmt.Sprintf("git -C %s archive --format=tar HEAD | tar -xf - -C %s", shell.Escape(project.SourceDir), shell.Escape(target.DeployDir))); err != nil {
+		s.discardRelease(ctx, target)
+		return "", "failed to export release", err
+	}
+	return commit, "", nil
+}
+
+// installArtifact builds the project locally, streams the tarball to the
+// server and unpacks it into the release once its checksum matches.
+func (s DeployService) installArtifact(ctx context.Context, project, target domain.Project, details map[string]string) (string, string, error) {
+	if s.Builder == nil {
+		return "", "artifact mode is not available", fmt.Errorf("%s uses artifact mode but no artifact builder is configured", project.Name)
+	}
+	artifact, err := s.Builder.Build(ctx, project)
+	if err != nil {
+		return "", "failed to build artifact", err
+	}
+	s.transcript.step("built artifact of %s (%d bytes, sha256 %s)", shortCommit(artifact.Commit), artifact.Size, artifact.SHA256)
+	defer func() {
+		if err := s.Builder.Remove(artifact); err != nil {
+			log.Printf("WARNING: failed to remove local artifact %s: %v", artifact.Path, err)
+		}
+	}()
+	details["checksum"] = artifact.SHA256
+
+	upload := fmt.Sprintf("%s/.artifact-%s.tgz", project.BaseDir, target.Release)
+	if err := s.FS.Upload(artifact.Path, upload); err != nil {
+		return "", "failed to upload artifact", err
+	}
+	s.transcript.step("uploaded artifact to %s", upload)
+
+	unpack := fmt.Sprintf("mkdir -p %s && tar -xzf %s -C %s", shell.Escape(target.DeployDir), shell.Escape(upload), shell.Escape(target.DeployDir))
+	if _, err := s.Exec.Run(ctx, shell.VerifiedUpload(artifact.SHA256, upload, unpack)); err != nil {
+		s.discardRelease(ctx, target)
+		if domain.ExitStatus(err) == shell.ChecksumMismatchStatus {
+			return "", "uploaded artifact is corrupt", fmt.Errorf("%w: %s", domain.ErrChecksumMismatch, upload)
+		}
+		return "", "failed to unpack artifact", err
+	}
+	return artifact.Commit, "", nil
+}
+
+func (s DeployService) hooks() hookRunner {
+	return hookRunner{remote: s.Exec, local: s.Local}
+}
+
+func (s DeployService) checkHealth(ctx context.Context, project domain.Project) error {
+	if len(project.HealthChecks) == 0 {
+		return nil
+	}
+	if s.Health == nil {
+		return fmt.Errorf("health checks configured for %s but no health checker available", project.Name)
+	}
+	err := verifyHealth(ctx, s.Health, s.Exec, project)
+	if err != nil {
+		s.transcript.step("health checks failed: %v", err)
+	} else {
+		s.transcript.step("health checks passed")
+	}
+	return err
+}
+
+// revert restores the previous release after the new one failed verification,
+// running the rollback hooks around the switch.
+func (s DeployService) revert(ctx context.Context, result domain.DeploymentResult, project domain.Project, previous, release, reason string, cause error) (domain.DeploymentResult, error) {
+	// The previous release is restored even when the deployment was cancelled.
+	ctx = context.WithoutCancel(ctx)
+	result.Status = "failed"
+	if previous == "" {
+		result.Message = fmt.Sprintf("release %s %s and there is no previous release to restore", release, reason)
+		return result, cause
+	}
+
+	hooks, err := s.hooks().run(ctx, project, domain.HookPreRollback, project.DeployDir)
+	result.Hooks = append(result.Hooks, hooks...)
+	if err != nil {
+		log.Printf("WARNING: continuing automatic rollback of %s: %v", project.Name, err)
+	}
+
+	if err := restoreRelease(ctx, s.Exec, s.FS, s.Strategies, project, previous); err != nil {
+		result.Message = fmt.Sprintf("release %s %s and restoring %s failed", release, reason, previous)
+		return result, fmt.Errorf("%w (rollback failed: %v)", cause, err)
+	}
+	s.discardRelease(ctx, project.WithRelease(release))
+
+	hooks, err = s.hooks().run(ctx, project, domain.HookPostRollback, project.DeployDir)
+	result.Hooks = append(result.Hooks, hooks...)
+	if err != nil {
+		log.Printf("WARNING: post-rollback hooks for %s failed: %v", project.Name, err)
+	}
+
+	result.Status = "rolled_back"
+	result.Message = fmt.Sprintf("release %s %s; rolled back to %s", release, reason, previous)
+	return result, cause
+}
+
+// discardRelease removes a release that never became active.
+func (s DeployService) discardRelease(ctx context.Context, project domain.Project) {
+	if err := removeRelease(context.WithoutCancel(ctx), s.Exec, project, project.Release); err != nil {
+		log.Printf("WARNING: failed to remove release %s for %s: %v", project.Release, project.Name, err)
+	}
+}
+
+// shortCommit abbreviates a commit SHA for messages.
+func shortCommit(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
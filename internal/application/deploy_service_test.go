@@ -0,0 +1,382 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"io"
+	iofs "io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dadyutenga/git-engine/internal/domain"
+)
+
+// memFS is an in-memory RemoteFileSystem. Mkdir creates missing parents.
+type memFS struct {
+	files map[string][]byte
+	dirs  map[string]bool
+	links map[string]string
+}
+
+func newMemFS() *memFS {
+	return &memFS{files: map[string][]byte{}, dirs: map[string]bool{}, links: map[string]string{}}
+}
+
+func (f *memFS) Exists(path string) (bool, error) {
+	_, file := f.files[path]
+	_, link := f.links[path]
+	return file || link || f.dirs[path], nil
+}
+
+func (f *memFS) Mkdir(path string, recursive bool) error {
+	for p := path; p != "/" && p != "."; p = filepath.Dir(p) {
+		f.dirs[p] = true
+	}
+	return nil
+}
+
+func (f *memFS) List(path string) ([]string, error) {
+	seen := map[string]bool{}
+	for _, p := range f.paths() {
+		if filepath.Dir(p) == path {
+			seen[filepath.Base(p)] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (f *memFS) ReadFile(path string) ([]byte, error) {
+	data, ok := f.files[path]
+	if !ok {
+		return nil, iofs.ErrNotExist
+	}
+	return data, nil
+}
+
+func (f *memFS) WriteFile(path string, data []byte, perm iofs.FileMode) error {
+	f.files[path] = data
+	return nil
+}
+
+func (f *memFS) AppendFile(path string, data []byte) error {
+	f.files[path] = append(f.files[path], data...)
+	return nil
+}
+
+func (f *memFS) Upload(localPath, path string) error { return nil }
+
+func (f *memFS) Download(path, localPath string) error { return nil }
+
+func (f *memFS) Stat(path string) (iofs.FileInfo, error) { return nil, iofs.ErrNotExist }
+
+func (f *memFS) Remove(path string) error {
+	if ok, _ := f.Exists(path); !ok {
+		return iofs.ErrNotExist
+	}
+	delete(f.files, path)
+	delete(f.links, path)
+	delete(f.dirs, path)
+	return nil
+}
+
+func (f *memFS) Rename(oldPath, newPath string) error {
+	if target, ok := f.links[oldPath]; ok {
+		delete(f.links, oldPath)
+		f.links[newPath] = target
+		return nil
+	}
+	if data, ok := f.files[oldPath]; ok {
+		delete(f.files, oldPath)
+		f.files[newPath] = data
+		return nil
+	}
+	if !f.dirs[oldPath] {
+		return iofs.ErrNotExist
+	}
+	for _, p := range f.paths() {
+		if p == oldPath || strings.HasPrefix(p, oldPath+"/") {
+			moved := newPath + strings.TrimPrefix(p, oldPath)
+			if data, ok := f.files[p]; ok {
+				f.files[moved] = data
+			}
+			if target, ok := f.links[p]; ok {
+				f.links[moved] = target
+			}
+			if f.dirs[p] {
+				f.dirs[moved] = true
+			}
+		}
+	}
+	f.removeAll(oldPath)
+	return nil
+}
+
+func (f *memFS) Symlink(target, link string) error {
+	f.links[link] = target
+	return nil
+}
+
+// removeAll deletes path and everything below it, like rm -rf.
+func (f *memFS) removeAll(path string) {
+	for _, p := range f.paths() {
+		if p == path || strings.HasPrefix(p, path+"/") {
+			delete(f.files, p)
+			delete(f.links, p)
+			delete(f.dirs, p)
+		}
+	}
+}
+
+func (f *memFS) paths() []string {
+	var paths []string
+	for p := range f.files {
+		paths = append(paths, p)
+	}
+	for p := range f.links {
+		paths = append(paths, p)
+	}
+	for p := range f.dirs {
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// serverExec plays the commands the services run against a memFS: readlink
+// and rm -rf act on it, git ls-remote answers with lsRemote and everything
+// else succeeds without output.
+type serverExec struct {
+	fs       *memFS
+	lsRemote string
+	commands []string
+}
+
+func (e *serverExec) Run(ctx context.Context, command string) (domain.CommandResult, error) {
+	e.commands = append(e.commands, command)
+	fields := strings.Fields(command)
+	switch {
+	case strings.HasPrefix(command, "readlink "):
+		return domain.CommandResult{Stdout: e.fs.links[strings.Trim(fields[1], "'")] + "\n"}, nil
+	case strings.HasPrefix(command, "rm -rf ") && len(fields) == 3:
+		e.fs.removeAll(strings.Trim(fields[2], "'"))
+	case strings.Contains(command, "git ls-remote"):
+		return domain.CommandResult{Stdout: e.lsRemote}, nil
+	}
+	return domain.CommandResult{}, nil
+}
+
+func (e *serverExec) RunStream(ctx context.Context, command string, writer io.Writer) error {
+	_, err := e.Run(ctx, command)
+	return err
+}
+
+// fakeLock grants the lock unless busy is set. Like a real lock it cannot be
+// acquired with a cancelled context.
+type fakeLock struct {
+	busy bool
+}
+
+func (l fakeLock) Acquire(ctx context.Context, project domain.Project) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return !l.busy, nil
+}
+
+func (l fakeLock) Release(ctx context.Context, project domain.Project) error {
+	return nil
+}
+
+// fakeStrategy matches every project after probing for its marker file and
+// records the release the current symlink pointed at on each restart.
+type fakeStrategy struct {
+	fs        *memFS
+	restarted []string
+}
+
+func (s *fakeStrategy) Name() string { return "fake" }
+
+func (s *fakeStrategy) Detect(ctx context.Context, fs RemoteFileSystem, project domain.Project, exec RemoteExecutor) (bool, error) {
+	_, err := exec.Run(ctx, "test -f "+project.DeployDir+"/fake.json")
+	return err == nil, nil
+}
+
+func (s *fakeStrategy) Deploy(ctx context.Context, project domain.Project, exec RemoteExecutor) error {
+	return nil
+}
+
+func (s *fakeStrategy) Restart(ctx context.Context, project domain.Project, exec RemoteExecutor) error {
+	s.restarted = append(s.restarted, filepath.Base(s.fs.links[project.CurrentLink]))
+	return nil
+}
+
+func (s *fakeStrategy) Status(ctx context.Context, project domain.Project, exec RemoteExecutor) (bool, error) {
+	return true, nil
+}
+
+func (s *fakeStrategy) BackupExcludes() []string { return nil }
+
+// healthFunc adapts a function to HealthChecker.
+type healthFunc func(ctx context.Context, project domain.Project, check domain.HealthCheck, exec RemoteExecutor) error
+
+func (f healthFunc) Check(ctx context.Context, project domain.Project, check domain.HealthCheck, exec RemoteExecutor) error {
+	return f(ctx, project, check, exec)
+}
+
+// memStore is a BackupStore keeping the manifests of its backups in memory.
+// Restoring a backup creates the directory on fs.
+type memStore struct {
+	fs      *memFS
+	backups []domain.Backup
+}
+
+func (s *memStore) Name() string { return domain.BackupStoreTarball }
+
+func (s *memStore) Create(ctx context.Context, project domain.Project, exec RemoteExecutor, backup domain.Backup) (domain.Backup, error) {
+	backup.Name = project.Name + "-" + backup.Release + ".tar.gz"
+	backup.Store = s.Name()
+	backup.HasManifest = true
+	s.backups = append(s.backups, backup)
+	return backup, nil
+}
+
+func (s *memStore) List(ctx context.Context, project domain.Project) ([]domain.Backup, error) {
+	return s.backups, nil
+}
+
+func (s *memStore) Verify(ctx context.Context, project domain.Project, exec RemoteExecutor, backup domain.Backup) error {
+	return nil
+}
+
+func (s *memStore) Restore(ctx context.Context, project domain.Project, exec RemoteExecutor, backup domain.Backup, dir string) error {
+	return s.fs.Mkdir(dir, true)
+}
+
+func (s *memStore) Remove(ctx context.Context, project domain.Project, exec RemoteExecutor, backup domain.Backup) error {
+	return nil
+}
+
+func (s *memStore) Export(ctx context.Context, project domain.Project, exec RemoteExecutor, backup domain.Backup) (string, domain.Backup, error) {
+	return "", backup, errors.ErrUnsupported
+}
+
+func (s *memStore) Import(ctx context.Context, project domain.Project, exec RemoteExecutor, backup domain.Backup, path string) (domain.Backup, error) {
+	return backup, errors.ErrUnsupported
+}
+
+// testServer is a server holding one live release of project.
+type testServer struct {
+	fs       *memFS
+	exec     *serverExec
+	strategy *fakeStrategy
+	store    *memStore
+}
+
+const liveRelease = "20240101000000"
+
+func newTestServer(project domain.Project) *testServer {
+	fsys := newMemFS()
+	fsys.Mkdir(project.ReleaseDir(liveRelease), true)
+	fsys.Mkdir(project.SharedDir, true)
+	fsys.Symlink(project.ReleaseDir(liveRelease), project.CurrentLink)
+	return &testServer{
+		fs:       fsys,
+		exec:     &serverExec{fs: fsys, lsRemote: "0123456789abcdef0123456789abcdef01234567\trefs/heads/main\n"},
+		strategy: &fakeStrategy{fs: fsys},
+		store:    &memStore{fs: fsys},
+	}
+}
+
+func (s *testServer) deployService(health HealthChecker) DeployService {
+	return DeployService{
+		Exec:         s.exec,
+		FS:           s.fs,
+		Lock:         fakeLock{},
+		Strategies:   []DeploymentStrategy{s.strategy},
+		Health:       health,
+		BackupStores: []BackupStore{s.store},
+	}
+}
+
+func (s *testServer) rollbackService() RollbackService {
+	return RollbackService{
+		Exec:         s.exec,
+		FS:           s.fs,
+		Lock:         fakeLock{},
+		Strategies:   []DeploymentStrategy{s.strategy},
+		BackupStores: []BackupStore{s.store},
+	}
+}
+
+// current returns the release the current symlink points at.
+func (s *testServer) current(project domain.Project) string {
+	return filepath.Base(s.fs.links[project.CurrentLink])
+}
+
+func TestDeployRevertsWhenHealthChecksFail(t *testing.T) {
+	tests := []struct {
+		name        string
+		health      error
+		wantStatus  string
+		wantOutcome string
+	}{
+		{name: "healthy", wantStatus: "deployed", wantOutcome: domain.OutcomeSuccess},
+		{name: "unhealthy", health: errors.New("connection refused"), wantStatus: "rolled_back", wantOutcome: domain.OutcomeRolledBack},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := domain.NewProject("shop")
+			project.HealthChecks = []domain.HealthCheck{{Type: "command", Command: "true"}}
+			server := newTestServer(project)
+			health := healthFunc(func(ctx context.Context, project domain.Project, check domain.HealthCheck, exec RemoteExecutor) error {
+				return tt.health
+			})
+
+			res, err := server.deployService(health).Deploy(context.Background(), project)
+			if tt.health == nil && err != nil {
+				t.Fatalf("Deploy: %v", err)
+			}
+			if tt.health != nil && !errors.Is(err, domain.ErrHealthCheckFailed) {
+				t.Fatalf("Deploy error = %v, want %v", err, domain.ErrHealthCheckFailed)
+			}
+			if res.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q (%s)", res.Status, tt.wantStatus, res.Message)
+			}
+			release := res.Details["release"]
+			if release == "" || res.Details["previous_release"] != liveRelease {
+				t.Fatalf("details = %v", res.Details)
+			}
+			if len(server.store.backups) != 1 || server.store.backups[0].Release != liveRelease {
+				t.Errorf("backups = %+v, want one of %s", server.store.backups, liveRelease)
+			}
+
+			wantCurrent, wantRestarts := release, []string{release}
+			if tt.health != nil {
+				wantCurrent, wantRestarts = liveRelease, []string{release, liveRelease}
+				if ok, _ := server.fs.Exists(project.ReleaseDir(release)); ok {
+					t.Errorf("failed release %s was not removed", release)
+				}
+			}
+			if got := server.current(project); got != wantCurrent {
+				t.Errorf("current release = %s, want %s", got, wantCurrent)
+			}
+			if strings.Join(server.strategy.restarted, ",") != strings.Join(wantRestarts, ",") {
+				t.Errorf("restarted %v, want %v", server.strategy.restarted, wantRestarts)
+			}
+
+			entries, err := HistoryService{FS: server.fs}.List(context.Background(), project, domain.HistoryFilter{})
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("history = %+v, %v", entries, err)
+			}
+			if entries[0].Outcome != tt.wantOutcome || entries[0].Release != release {
+				t.Errorf("history entry = %+v, want outcome %s of %s", entries[0], tt.wantOutcome, release)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"github.com/dadyutenga/git-engine/internal/shared/shell"
+)
+
+// envExecutor exports a fixed set of variables before every command.
+type envExecutor struct {
+	exec   RemoteExecutor
+	prefix string
+}
+
+// withEnv wraps exec so that env is exported for each command. It returns
+// exec unchanged when env is empty.
+func withEnv(exec RemoteExecutor, env map[string]string) RemoteExecutor {
+	if len(env) == 0 {
+		return exec
+	}
+	return envExecutor{exec: exec, prefix: envPrefix(env)}
+}
+
+// envPrefix renders the export statement withEnv puts before each command.
+func envPrefix(env map[string]string) string {
+	if len(env) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	assignments := make([]string, 0, len(keys))
+	for _, k := range keys {
+		assignments = append(assignments, k+"="+shell.Escape(env[k]))
+	}
+	return "export " + strings.Join(assignments, " ") + "; "
+}
+
+// Run executes command with the variables exported. The result and errors
+// report command without the exports, keeping their values out of messages.
+func (e envExecutor) Run(ctx context.Context, command string) (domain.CommandResult, error) {
+	result, err := e.exec.Run(ctx, e.prefix+command)
+	result.Command = command
+	return result, e.unprefixed(err, command)
+}
+
+func (e envExecutor) RunStream(ctx context.Context, command string, writer io.Writer) error {
+	return e.unprefixed(e.exec.RunStream(ctx, e.prefix+command, writer), command)
+}
+
+func (e envExecutor) unprefixed(err error, command string) error {
+	var cmdErr *domain.RemoteCommandError
+	if errors.As(err, &cmdErr) {
+		cmdErr.Result.Command = command
+	}
+	return err
+}
@@ -0,0 +1,176 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dadyutenga/git-engine/internal/domain"
+)
+
+// FleetDeployService deploys a project to several hosts.
+type FleetDeployService struct {
+	Connector HostConnector
+	Policy    domain.RolloutPolicy
+}
+
+// Deploy rolls the deployment out batch by batch. Hosts within a batch are
+// deployed concurrently. Once more than Policy.MaxFailures hosts have failed
+// the remaining batches are skipped and, if Policy.RollbackOnHalt is set, the
+// hosts already updated are rolled back to their previous release.
+// A host that cannot be reached counts as failed.
+func (s FleetDeployService) Deploy(ctx context.Context, project domain.Project, hosts []string) domain.DeploymentSummary {
+	summary := domain.DeploymentSummary{ProjectName: project.Name, Timestamp: time.Now()}
+	results := make(map[string]domain.HostDeploymentResult, len(hosts))
+	open := map[string]HostServices{}
+	defer func() {
+		for host, services := range open {
+			closeHost(host, services)
+		}
+	}()
+
+	failures := 0
+	batches := s.Policy.Batches(hosts)
+	for i, batch := range batches {
+		if i > 0 {
+			err := ctx.Err()
+			if err == nil && s.Policy.Pause > 0 {
+				log.Printf("rollout of %s: pausing %s before batch %d/%d", project.Name, s.Policy.Pause, i+1, len(batches))
+				err = sleep(ctx, s.Policy.Pause)
+			}
+			if err != nil {
+				summary.Halted = true
+				skipHosts(project, batches[i:], results, "skipped after the rollout was cancelled")
+				if s.Policy.RollbackOnHalt {
+					s.rollbackUpdated(ctx, project, results, open)
+				}
+				break
+			}
+		}
+
+		for host, res := range s.deployBatch(ctx, project, batch, open) {
+			results[host] = res
+			if !res.Result.Success {
+				failures++
+			}
+		}
+
+		if failures > s.Policy.MaxFailures {
+			summary.Halted = true
+			skipHosts(project, batches[i+1:], results, "skipped after the rollout halted")
+			if s.Policy.RollbackOnHalt {
+				s.rollbackUpdated(ctx, project, results, open)
+			}
+			break
+		}
+	}
+
+	for _, host := range hosts {
+		res := results[host]
+		switch {
+		case res.Result.Success:
+			summary.Succeeded++
+		case res.Result.Status == "skipped":
+			summary.Skipped++
+		case res.Result.Status == "rolled_back" && res.Err == nil:
+			summary.RolledBack++
+		default:
+			summary.Failed++
+		}
+		summary.Hosts = append(summary.Hosts, res)
+	}
+	return summary
+}
+
+// skipHosts records every host of batches as skipped with message.
+func skipHosts(project domain.Project, batches [][]string, results map[string]domain.HostDeploymentResult, message string) {
+	for _, batch := range batches {
+		for _, host := range batch {
+			results[host] = domain.HostDeploymentResult{Host: host, Result: domain.DeploymentResult{
+				ProjectName: project.Name,
+				Status:      "skipped",
+				Message:     message,
+				Timestamp:   time.Now(),
+			}}
+		}
+	}
+}
+
+// deployBatch deploys to every host of a batch concurrently.
+func (s FleetDeployService) deployBatch(ctx context.Context, project domain.Project, batch []string, open map[string]HostServices) map[string]domain.HostDeploymentResult {
+	var mu sync.Mutex
+	results := make(map[string]domain.HostDeploymentResult, len(batch))
+	var wg sync.WaitGroup
+	for _, host := range batch {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			res := domain.HostDeploymentResult{Host: host}
+			services, err := s.Connector.Connect(host)
+			connected := err == nil
+			if connected {
+				res.Result, err = services.Deploy.Deploy(ctx, project)
+			} else {
+				res.Result = domain.DeploymentResult{ProjectName: project.Name, Status: "failed", Message: "failed to connect", Timestamp: time.Now()}
+			}
+			if err != nil {
+				res.Err = err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if connected {
+				open[host] = services
+			}
+			results[host] = res
+		}(host)
+	}
+	wg.Wait()
+	return results
+}
+
+// rollbackUpdated restores the previous release on every host that was
+// successfully updated during this rollout. It still runs when ctx was
+// cancelled, as cancelling is one way a rollout halts.
+func (s FleetDeployService) rollbackUpdated(ctx context.Context, project domain.Project, results map[string]domain.HostDeploymentResult, open map[string]HostServices) {
+	ctx = context.WithoutCancel(ctx)
+	for host, res := range results {
+		if !res.Result.Success {
+			continue
+		}
+		services, ok := open[host]
+		previous := res.Result.Details["previous_release"]
+		res.Result.Success = false
+		res.Result.Status = "rolled_back"
+		switch {
+		case !ok:
+			res.Err = errors.New("connection closed before rollback")
+		case previous == "":
+			res.Err = errors.New("no previous release to roll back to")
+		default:
+			rb, err := services.Rollback.Rollback(ctx, project, previous, "", false)
+			res.Result.Hooks = append(res.Result.Hooks, rb.Hooks...)
+			if err != nil {
+				res.Err = fmt.Errorf("rollback failed: %w", err)
+			}
+		}
+		if res.Err == nil {
+			res.Result.Message = fmt.Sprintf("rolled back to %s after the rollout halted", previous)
+		} else {
+			res.Result.Message = "rollout halted and rollback did not complete"
+		}
+		results[host] = res
+	}
+}
+
+func closeHost(host string, services HostServices) {
+	if services.Close == nil {
+		return
+	}
+	if err := services.Close(); err != nil {
+		log.Printf("WARNING: failed to close connection to %s: %v", host, err)
+	}
+}
@@ -0,0 +1,105 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dadyutenga/git-engine/internal/domain"
+)
+
+// fleetConnector connects to test servers. The health checks of a host are
+// run by health[host] and pass when it has none.
+type fleetConnector struct {
+	servers map[string]*testServer
+	health  map[string]HealthChecker
+}
+
+func (c fleetConnector) Connect(host string) (HostServices, error) {
+	server, ok := c.servers[host]
+	if !ok {
+		return HostServices{}, errors.New("no route to host")
+	}
+	health, ok := c.health[host]
+	if !ok {
+		health = healthFunc(func(ctx context.Context, project domain.Project, check domain.HealthCheck, exec RemoteExecutor) error {
+			return nil
+		})
+	}
+	return HostServices{Deploy: server.deployService(health), Rollback: server.rollbackService()}, nil
+}
+
+func TestFleetRollsBackUpdatedHostsWhenHalted(t *testing.T) {
+	failing := healthFunc(func(ctx context.Context, project domain.Project, check domain.HealthCheck, exec RemoteExecutor) error {
+		return errors.New("connection refused")
+	})
+	tests := []struct {
+		name string
+		// health returns the health checker of web1 and web2; cancel stops
+		// the rollout.
+		health     func(cancel context.CancelFunc) map[string]HealthChecker
+		policy     domain.RolloutPolicy
+		wantStatus map[string]string
+		wantLive   map[string]bool
+	}{
+		{
+			name: "failure",
+			health: func(cancel context.CancelFunc) map[string]HealthChecker {
+				return map[string]HealthChecker{"web2": failing}
+			},
+			policy:     domain.RolloutPolicy{BatchSize: 1, RollbackOnHalt: true},
+			wantStatus: map[string]string{"web1": "rolled_back", "web2": "rolled_back", "web3": "skipped"},
+		},
+		{
+			name: "failure without rollback",
+			health: func(cancel context.CancelFunc) map[string]HealthChecker {
+				return map[string]HealthChecker{"web2": failing}
+			},
+			policy:     domain.RolloutPolicy{BatchSize: 1},
+			wantStatus: map[string]string{"web1": "deployed", "web2": "rolled_back", "web3": "skipped"},
+			wantLive:   map[string]bool{"web1": true},
+		},
+		{
+			name: "cancelled during the pause",
+			health: func(cancel context.CancelFunc) map[string]HealthChecker {
+				return map[string]HealthChecker{"web1": healthFunc(func(ctx context.Context, project domain.Project, check domain.HealthCheck, exec RemoteExecutor) error {
+					cancel()
+					return nil
+				})}
+			},
+			policy:     domain.RolloutPolicy{BatchSize: 1, Pause: time.Hour, RollbackOnHalt: true},
+			wantStatus: map[string]string{"web1": "rolled_back", "web2": "skipped", "web3": "skipped"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			project := domain.NewProject("shop")
+			project.HealthChecks = []domain.HealthCheck{{Type: "command", Command: "true"}}
+			hosts := []string{"web1", "web2", "web3"}
+			connector := fleetConnector{servers: map[string]*testServer{}, health: tt.health(cancel)}
+			for _, host := range hosts {
+				connector.servers[host] = newTestServer(project)
+			}
+
+			summary := FleetDeployService{Connector: connector, Policy: tt.policy}.Deploy(ctx, project, hosts)
+			if !summary.Halted {
+				t.Errorf("rollout did not halt")
+			}
+			for _, res := range summary.Hosts {
+				if res.Result.Status != tt.wantStatus[res.Host] {
+					t.Errorf("%s: status = %q, want %q (%s, %v)", res.Host, res.Result.Status, tt.wantStatus[res.Host], res.Result.Message, res.Err)
+				}
+				if res.Host == "web1" && res.Result.Status == "rolled_back" && res.Err != nil {
+					t.Errorf("web1: rollback failed: %v", res.Err)
+				}
+				server := connector.servers[res.Host]
+				if got := server.current(project); (got != liveRelease) != tt.wantLive[res.Host] {
+					t.Errorf("%s: current release = %s, live release was %s", res.Host, got, liveRelease)
+				}
+			}
+		})
+	}
+}
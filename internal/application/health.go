@@ -0,0 +1,61 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dadyutenga/git-engine/internal/domain"
+)
+
+const (
+	defaultHealthInterval = 2 * time.Second
+	maxHealthInterval     = 30 * time.Second
+)
+
+// verifyHealth runs every configured health check, retrying each one with
+// exponential backoff, and returns the first check that never passed.
+func verifyHealth(ctx context.Context, checker HealthChecker, exec RemoteExecutor, project domain.Project) error {
+	for _, check := range project.HealthChecks {
+		if err := retryHealthCheck(ctx, checker, exec, project, check); err != nil {
+			return fmt.Errorf("%w: %s: %v", domain.ErrHealthCheckFailed, check.Describe(), err)
+		}
+	}
+	return nil
+}
+
+func retryHealthCheck(ctx context.Context, checker HealthChecker, exec RemoteExecutor, project domain.Project, check domain.HealthCheck) error {
+	retries := max(check.Retries, 0)
+	interval := check.Interval
+	if interval <= 0 {
+		interval = defaultHealthInterval
+	}
+
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			log.Printf("health check %s failed (attempt %d/%d): %v; retrying in %s", check.Describe(), attempt, retries+1, err, interval)
+			if err := sleep(ctx, interval); err != nil {
+				return err
+			}
+			interval = min(interval*2, maxHealthInterval)
+		}
+		if err = checker.Check(ctx, project, check, exec); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// sleep pauses for d, returning early with the context error when ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
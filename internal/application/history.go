@@ -0,0 +1,67 @@
+package application
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+
+	"github.com/dadyutenga/git-engine/internal/domain"
+)
+
+// recordHistory appends entry to the project's history ledger. A failure is
+// only logged so that the ledger never changes the outcome of an operation.
+func recordHistory(fsys RemoteFileSystem, project domain.Project, entry domain.HistoryEntry) {
+	line, err := json.Marshal(entry)
+	if err == nil {
+		err = fsys.AppendFile(project.HistoryFile, append(line, '\n'))
+	}
+	if err != nil {
+		log.Printf("WARNING: failed to record %s %s in the history of %s: %v", entry.Operation, entry.ID, project.Name, err)
+	}
+}
+
+// HistoryService reads the deployment history ledger of a project.
+type HistoryService struct {
+	FS RemoteFileSystem
+}
+
+// List returns the entries matching filter, oldest first. Lines that cannot
+// be parsed are skipped with a warning.
+func (s HistoryService) List(ctx context.Context, project domain.Project, filter domain.HistoryFilter) ([]domain.HistoryEntry, error) {
+	data, err := s.FS.ReadFile(project.HistoryFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []domain.HistoryEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry domain.HistoryEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			log.Printf("WARNING: skipping line %d of %s: %v", n, project.HistoryFile, err)
+			continue
+		}
+		if filter.Match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+	return entries, nil
+}
@@ -0,0 +1,64 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"github.com/dadyutenga/git-engine/internal/shared/shell"
+)
+
+// hookRunner executes lifecycle hooks remotely or on the local machine.
+type hookRunner struct {
+	remote RemoteExecutor
+	local  RemoteExecutor
+}
+
+// run executes the hooks of stage in order. Remote hooks run inside dir.
+// It stops at the first failing hook that is not marked as a warning and
+// returns the results gathered so far together with the error.
+func (h hookRunner) run(ctx context.Context, project domain.Project, stage, dir string) ([]domain.HookResult, error) {
+	hooks := project.Hooks[stage]
+	results := make([]domain.HookResult, 0, len(hooks))
+	for _, hook := range hooks {
+		res := domain.HookResult{Stage: stage, Name: hook.Name, Command: hook.Command, Local: hook.Local}
+
+		var out domain.CommandResult
+		var err error
+		hookCtx := UserCommand(ctx)
+		if hook.Local {
+			if h.local == nil {
+				err = fmt.Errorf("local hooks are not supported here")
+			} else {
+				out, err = withEnv(h.local, project.Env).Run(hookCtx, hook.Command)
+			}
+		} else {
+			out, err = withEnv(h.remote, project.Env).Run(hookCtx, fmt.Sprintf("cd %s && %s", shell.Escape(dir), hook.Command))
+		}
+		res.Output = strings.TrimSpace(out.Output())
+		res.Success = err == nil
+		if err != nil {
+			res.Error = err.Error()
+		}
+		results = append(results, res)
+
+		if err == nil {
+			continue
+		}
+		if hook.Warn {
+			log.Printf("WARNING: %s hook %q failed: %v", stage, hookLabel(hook), err)
+			continue
+		}
+		return results, fmt.Errorf("%s hook %q failed: %w", stage, hookLabel(hook), err)
+	}
+	return results, nil
+}
+
+func hookLabel(hook domain.Hook) string {
+	if hook.Name != "" {
+		return hook.Name
+	}
+	return hook.Command
+}
@@ -1,6 +1,7 @@
 package application
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -14,24 +15,57 @@ import (
 type InitService struct {
 	Exec RemoteExecutor
 	FS   RemoteFileSystem
+	// Agent is the deploy binary the post-receive hook runs on the server;
+	// empty means "deploy" from the PATH.
+	Agent string
+	// Actor is recorded in the transcript as the initiator.
+	Actor domain.Actor
 }
 
-// Init creates the remote scaffold and validates SSH connectivity.
-func (s InitService) Init(projectName string) (domain.InitResult, error) {
-	project := domain.NewProject(projectName)
+// Init creates the remote scaffold and validates SSH connectivity. The source
+// checkout is cloned from the bare repository so origin points at it, and a
+// post-receive hook is installed so pushing the project branch deploys it. A
+// checkout deployed in place in the base directory becomes the first release.
+func (s InitService) Init(ctx context.Context, project domain.Project) (domain.InitResult, error) {
 	now := time.Now()
+	result := domain.InitResult{ID: domain.NewDeploymentID(now), Project: project, Timestamp: now}
 
-	uidOut, err := s.Exec.Run("id -u")
+	t := newTranscript(s.FS, project, result.ID)
+	t.start(domain.OperationInit, s.Actor, nil)
+	s.Exec = t.executor(s.Exec, "")
+
+	message, err := s.init(ctx, project, t)
+	result.Success, result.Message = err == nil, message
+	outcome := domain.OutcomeSuccess
+	if err != nil {
+		outcome = domain.OutcomeFailed
+	}
+	t.finish(domain.OperationInit, outcome, message, err)
+	return result, err
+}
+
+func (s InitService) init(ctx context.Context, project domain.Project, t *transcript) (string, error) {
+	uidOut, err := s.Exec.Run(Retryable(ctx), "id -u")
 	if err != nil {
-		return domain.InitResult{Project: project, Success: false, Message: "failed to verify remote user", Timestamp: now}, err
+		return "failed to verify remote user", err
 	}
-	if strings.TrimSpace(uidOut) == "0" {
-		return domain.InitResult{Project: project, Success: false, Message: "refusing to run as root user", Timestamp: now}, fmt.Errorf("remote user is root")
+	if strings.TrimSpace(uidOut.Stdout) == "0" {
+		return "refusing to run as root user", fmt.Errorf("remote user is root")
+	}
+
+	release := domain.NewReleaseID(time.Now())
+	out, err := s.Exec.Run(ctx, migrateCommand(project, release))
+	if err != nil {
+		return "failed to move the existing checkout into a release", err
+	}
+	if strings.TrimSpace(out.Stdout) == "migrated" {
+		t.step("moved the checkout in %s into release %s", project.BaseDir, release)
 	}
 
 	paths := []string{
 		project.RepoPath,
-		project.DeployDir,
+		project.ReleasesDir,
+		project.SharedDir,
 		project.BackupDir,
 		filepath.Dir(project.LogFile),
 		filepath.Dir(project.LockFile),
@@ -39,14 +73,77 @@ func (s InitService) Init(projectName string) (domain.InitResult, error) {
 
 	for _, p := range paths {
 		if err := s.FS.Mkdir(p, true); err != nil {
-			return domain.InitResult{Project: project, Success: false, Message: fmt.Sprintf("unable to create %s", p), Timestamp: now}, err
+			return fmt.Sprintf("unable to create %s", p), err
 		}
 	}
+	t.step("created %s", strings.Join(paths, " "))
+
+	if _, err := s.Exec.Run(ctx, fmt.Sprintf("test -f %s/HEAD || git init --bare --quiet %s", shell.Escape(project.RepoPath), shell.Escape(project.RepoPath))); err != nil {
+		return "failed to initialize bare repository", err
+	}
 
-	if _, err := s.Exec.Run(fmt.Sprintf("test -d %s || git init --bare %s", shell.Escape(project.RepoPath), shell.Escape(project.RepoPath))); err != nil {
-		return domain.InitResult{Project: project, Success: false, Message: "failed to initialize bare repository", Timestamp: now}, err
+	if _, err := s.Exec.Run(ctx, fmt.Sprintf("git -C %s config receive.advertisePushOptions true", shell.Escape(project.RepoPath))); err != nil {
+		return "failed to configure bare repository", err
+	}
+	if err := s.FS.WriteFile(project.RepoPath+"/hooks/post-receive", []byte(s.postReceiveHook(project)), 0o755); err != nil {
+		return "failed to install post-receive hook", err
 	}
+	t.step("installed post-receive hook")
+
+	source, repo := shell.Escape(project.SourceDir), shell.Escape(project.RepoPath)
+	if _, err := s.Exec.Run(ctx, fmt.Sprintf("(test -d %s/.git || git clone --quiet %s %s) && git -C %s remote set-url origin %s", source, repo, source, source, repo)); err != nil {
+		return "failed to clone source checkout", err
+	}
+
+	return fmt.Sprintf("project %s initialized at %s", project.Name, project.DeployDir), nil
+}
+
+// migrateCommand moves a checkout deployed in place in the project's base
+// directory, as earlier versions did, into release, links current to it and
+// moves its .env into the shared directory. It prints "migrated" when it did.
+func migrateCommand(project domain.Project, release string) string {
+	base, target := shell.Escape(project.BaseDir), shell.Escape(project.ReleaseDir(release))
+	shared := shell.Escape(project.SharedDir)
+	return fmt.Sprintf(`if [ -d %[1]s/.git ] && [ ! -e %[1]s/current ]; then
+	mkdir -p %[2]s %[3]s || exit 1
+	for f in %[1]s/* %[1]s/.[!.]* %[1]s/..?*; do
+		[ -e "$f" ] || continue
+		case "$(basename "$f")" in repo|releases|shared|current) continue ;; esac
+		mv "$f" %[2]s/ || exit 1
+	done
+	if [ -f %[2]s/.env ] && [ ! -e %[3]s/.env ]; then mv %[2]s/.env %[3]s/.env && ln -s %[3]s/.env %[2]s/.env || exit 1; fi
+	ln -s %[2]s %[1]s/current && echo migrated
+fi`, base, target, shared)
+}
 
-	message := fmt.Sprintf("project %s initialized at %s", project.Name, project.DeployDir)
-	return domain.InitResult{Project: project, Success: true, Message: message, Timestamp: now}, nil
+// SkipPushOption is the git push option that stops the post-receive hook from
+// deploying, used when the CLI pushes sources as part of its own deployment.
+const SkipPushOption = "deploy=skip"
+
+// postReceiveHook renders the hook that runs "deploy agent run" for pushes to
+// the project branch, using the deploy.yaml of the pushed commit.
+func (s InitService) postReceiveHook(project domain.Project) string {
+	agent := s.Agent
+	if agent == "" {
+		agent = "deploy"
+	}
+	return fmt.Sprintf(`#!/bin/sh
+# Installed by deploy init: deploys %[1]s when %[2]s is pushed.
+i=0
+while [ "$i" -lt "${GIT_PUSH_OPTION_COUNT:-0}" ]; do
+	eval "option=\$GIT_PUSH_OPTION_$i"
+	[ "$option" = %[3]s ] && exit 0
+	i=$((i + 1))
+done
+while read -r oldrev newrev refname; do
+	[ "$refname" = %[4]s ] || continue
+	case "$newrev" in *[!0]*) ;; *) continue ;; esac
+	manifest=$(mktemp)
+	git show "$newrev:deploy.yaml" > "$manifest" 2>/dev/null || : > "$manifest"
+	(unset GIT_DIR; DEPLOY_MANIFEST="$manifest" %[5]s agent run -branch %[2]s -commit "$newrev" %[1]s)
+	status=$?
+	rm -f "$manifest"
+	exit $status
+done
+`, shell.Escape(project.Name), shell.Escape(project.Branch), shell.Escape(SkipPushOption), shell.Escape("refs/heads/"+project.Branch), shell.Escape(agent))
 }
@@ -1,6 +1,7 @@
 package application
 
 import (
+	"context"
 	"fmt"
 	"io"
 
@@ -13,12 +14,33 @@ type LogsService struct {
 	Exec RemoteExecutor
 }
 
+// TailOptions selects the log lines to stream.
+type TailOptions struct {
+	Lines  int
+	Follow bool
+	// DeployID limits the output to the transcript of one operation; Lines is
+	// ignored then and the whole transcript is shown.
+	DeployID string
+}
+
 // Tail streams the last N lines and follows updates.
-func (s LogsService) Tail(projectName string, lines int, follow bool, writer io.Writer) error {
-	project := domain.NewProject(projectName)
-	cmd := fmt.Sprintf("tail -n %d %s", lines, shell.Escape(project.LogFile))
-	if follow {
-		cmd = fmt.Sprintf("tail -n %d -F %s", lines, shell.Escape(project.LogFile))
+func (s LogsService) Tail(ctx context.Context, project domain.Project, opts TailOptions, writer io.Writer) error {
+	logFile := shell.Escape(project.LogFile)
+	if opts.DeployID == "" {
+		cmd := fmt.Sprintf("tail -n %d %s", opts.Lines, logFile)
+		if opts.Follow {
+			cmd = fmt.Sprintf("tail -n %d -F %s", opts.Lines, logFile)
+		}
+		return s.Exec.RunStream(ctx, cmd, writer)
+	}
+
+	match := shell.Escape(transcriptPrefix(opts.DeployID))
+	if opts.Follow {
+		return s.Exec.RunStream(ctx, fmt.Sprintf("tail -n +1 -F %s | grep --line-buffered -F -- %s", logFile, match), writer)
+	}
+	err := s.Exec.RunStream(ctx, fmt.Sprintf("grep -F -- %s %s", match, logFile), writer)
+	if domain.ExitStatus(err) == 1 {
+		return fmt.Errorf("no transcript found for deploy %s", opts.DeployID)
 	}
-	return s.Exec.RunStream(cmd, writer)
+	return err
 }
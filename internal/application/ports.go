@@ -1,35 +1,170 @@
 package application
 
 import (
+	"context"
 	"io"
+	"io/fs"
 
 	"github.com/dadyutenga/git-engine/internal/domain"
 )
 
-// RemoteExecutor abstracts remote command execution over SSH.
+// RemoteExecutor abstracts remote command execution over SSH. Cancelling ctx
+// stops the remote command. Commands that fail return a
+// *domain.RemoteCommandError.
 type RemoteExecutor interface {
-	Run(command string) (string, error)
-	RunStream(command string, writer io.Writer) error
+	Run(ctx context.Context, command string) (domain.CommandResult, error)
+	RunStream(ctx context.Context, command string, writer io.Writer) error
 }
 
-// RemoteFileSystem offers simple remote file operations.
+// commandKey keys the markers executors read from a command's context.
+type commandKey int
+
+const (
+	userCommandKey commandKey = iota
+	retryableKey
+)
+
+// UserCommand marks the commands run with ctx as user-supplied shell, such as
+// hooks. Dry runs only record them, however harmless they look.
+func UserCommand(ctx context.Context) context.Context {
+	return context.WithValue(ctx, userCommandKey, true)
+}
+
+// IsUserCommand reports whether ctx was marked by UserCommand.
+func IsUserCommand(ctx context.Context) bool {
+	marked, _ := ctx.Value(userCommandKey).(bool)
+	return marked
+}
+
+// Retryable marks the commands run with ctx as safe to run again, such as
+// probes that change nothing. Executors only retry commands marked this way.
+func Retryable(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryableKey, true)
+}
+
+// IsRetryable reports whether ctx was marked by Retryable.
+func IsRetryable(ctx context.Context) bool {
+	marked, _ := ctx.Value(retryableKey).(bool)
+	return marked
+}
+
+// RemoteFileSystem offers remote file operations.
 type RemoteFileSystem interface {
 	Exists(path string) (bool, error)
 	Mkdir(path string, recursive bool) error
 	List(path string) ([]string, error)
+	ReadFile(path string) ([]byte, error)
+	WriteFile(path string, data []byte, perm fs.FileMode) error
+	// AppendFile appends data to path, creating it when missing.
+	AppendFile(path string, data []byte) error
+	// Upload copies a local file to path.
+	Upload(localPath, path string) error
+	// Download copies path to a local file.
+	Download(path, localPath string) error
+	Stat(path string) (fs.FileInfo, error)
+	// Remove deletes a file or an empty directory.
+	Remove(path string) error
+	// Rename moves oldPath to newPath, atomically replacing newPath.
+	Rename(oldPath, newPath string) error
+	Symlink(target, link string) error
 }
 
 // LockManager coordinates distributed deployment locks.
 type LockManager interface {
-	Acquire(project domain.Project) (bool, error)
-	Release(project domain.Project) error
+	Acquire(ctx context.Context, project domain.Project) (bool, error)
+	Release(ctx context.Context, project domain.Project) error
 }
 
 // DeploymentStrategy implements detection and deployment for a project type.
 type DeploymentStrategy interface {
 	Name() string
-	Detect(fs RemoteFileSystem, project domain.Project) (bool, error)
-	Deploy(project domain.Project, exec RemoteExecutor) error
-	Restart(project domain.Project, exec RemoteExecutor) error
-	Status(project domain.Project, exec RemoteExecutor) (bool, error)
+	// Detect reports whether the strategy handles project, probing the
+	// server through fs and exec.
+	Detect(ctx context.Context, fs RemoteFileSystem, project domain.Project, exec RemoteExecutor) (bool, error)
+	// Deploy builds a release that is not live yet. It must leave the running
+	// services alone.
+	Deploy(ctx context.Context, project domain.Project, exec RemoteExecutor) error
+	// Restart points the running services at the active release.
+	Restart(ctx context.Context, project domain.Project, exec RemoteExecutor) error
+	Status(ctx context.Context, project domain.Project, exec RemoteExecutor) (bool, error)
+	// BackupExcludes lists tar patterns a backup of a release may skip
+	// because Deploy rebuilds them.
+	BackupExcludes() []string
+}
+
+// BackupStore keeps the backups of replaced releases. Like strategies, it
+// runs its commands through the executor it is given.
+type BackupStore interface {
+	Name() string
+	// Create backs up the release named by backup.Release, leaving out
+	// backup.Excludes, and writes its manifest. The store fills in Name,
+	// Store, SHA256 and Size.
+	Create(ctx context.Context, project domain.Project, exec RemoteExecutor, backup domain.Backup) (domain.Backup, error)
+	// List returns the backups of project held by the store, in any order.
+	List(ctx context.Context, project domain.Project) ([]domain.Backup, error)
+	// Verify checks that a backup still matches its manifest.
+	Verify(ctx context.Context, project domain.Project, exec RemoteExecutor, backup domain.Backup) error
+	// Restore copies the content of a backup into the existing directory dir.
+	Restore(ctx context.Context, project domain.Project, exec RemoteExecutor, backup domain.Backup, dir string) error
+	// Remove deletes a backup and its manifest.
+	Remove(ctx context.Context, project domain.Project, exec RemoteExecutor, backup domain.Backup) error
+	// Export returns the path of a single archive on the server holding a
+	// backup, and the manifest describing that archive. An archive other
+	// than the backup itself is temporary and removed by the caller.
+	Export(ctx context.Context, project domain.Project, exec RemoteExecutor, backup domain.Backup) (string, domain.Backup, error)
+	// Import checks the archive uploaded to path against the manifest backup
+	// and turns it into a backup of the store. The upload is consumed.
+	Import(ctx context.Context, project domain.Project, exec RemoteExecutor, backup domain.Backup, path string) (domain.Backup, error)
+}
+
+// DatabaseDumper dumps and restores project databases on the server. Like
+// strategies, it runs its commands through the executor it is given.
+type DatabaseDumper interface {
+	// Dump writes a compressed dump of db to path.
+	Dump(ctx context.Context, exec RemoteExecutor, db domain.Database, path string) (domain.DatabaseDump, error)
+	// Restore loads the dump at path into db.
+	Restore(ctx context.Context, exec RemoteExecutor, db domain.Database, path string) error
+}
+
+// LocalArchives keeps copies of backup archives on the machine running the
+// CLI, each next to its manifest.
+type LocalArchives interface {
+	// Fetch downloads the archive at path on the server into dir under the
+	// name of backup and returns the local path. A copy that does not match
+	// the manifest is removed.
+	Fetch(fs RemoteFileSystem, path string, backup domain.Backup, dir string) (string, error)
+	// Open returns the manifest of the local archive at path after checking
+	// the archive against it. An archive without a manifest is described by
+	// its name, size and checksum.
+	Open(path string) (domain.Backup, error)
+}
+
+// SourcePusher publishes the local sources to the project's bare repository,
+// running git in the working repository through the local executor it is given.
+type SourcePusher interface {
+	Push(ctx context.Context, project domain.Project, local RemoteExecutor) error
+}
+
+// ArtifactBuilder builds a project locally and packages it for upload.
+type ArtifactBuilder interface {
+	Build(ctx context.Context, project domain.Project) (domain.Artifact, error)
+	Remove(artifact domain.Artifact) error
+}
+
+// HealthChecker performs a single attempt of a post-deploy health check,
+// running its probes through the executor it is given.
+type HealthChecker interface {
+	Check(ctx context.Context, project domain.Project, check domain.HealthCheck, exec RemoteExecutor) error
+}
+
+// HostServices are the services bound to a single remote host.
+type HostServices struct {
+	Deploy   DeployService
+	Rollback RollbackService
+	Close    func() error
+}
+
+// HostConnector opens the services of a named host for fleet operations.
+type HostConnector interface {
+	Connect(host string) (HostServices, error)
 }
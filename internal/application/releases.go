@@ -0,0 +1,134 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	iofs "io/fs"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"github.com/dadyutenga/git-engine/internal/shared/shell"
+)
+
+// releasesToKeep bounds how many release directories survive a deployment.
+const releasesToKeep = 5
+
+// listReleases returns release identifiers sorted from oldest to newest.
+func listReleases(fs RemoteFileSystem, project domain.Project) ([]string, error) {
+	exists, err := fs.Exists(project.ReleasesDir)
+	if err != nil || !exists {
+		return []string{}, err
+	}
+	releases, err := fs.List(project.ReleasesDir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(releases)
+	return releases, nil
+}
+
+// currentRelease resolves the release the current symlink points at.
+// An empty string means no release has been activated yet.
+func currentRelease(ctx context.Context, exec RemoteExecutor, project domain.Project) (string, error) {
+	out, err := exec.Run(Retryable(ctx), fmt.Sprintf("readlink %s || true", shell.Escape(project.CurrentLink)))
+	if err != nil {
+		return "", err
+	}
+	target := strings.TrimSpace(out.Stdout)
+	if target == "" {
+		return "", nil
+	}
+	return filepath.Base(target), nil
+}
+
+// linkShared symlinks every entry of the shared directory into the release.
+func linkShared(ctx context.Context, exec RemoteExecutor, project domain.Project) error {
+	cmd := fmt.Sprintf(`cd %s && for f in %s/* %s/.[!.]*; do [ -e "$f" ] || continue; n=$(basename "$f"); rm -rf "./$n"; ln -s "$f" "./$n"; done`,
+		shell.Escape(project.DeployDir), shell.Escape(project.SharedDir), shell.Escape(project.SharedDir))
+	_, err := exec.Run(ctx, cmd)
+	return err
+}
+
+// activateRelease atomically points the current symlink at the release by
+// renaming a freshly created link over it.
+func activateRelease(fs RemoteFileSystem, project domain.Project, release string) error {
+	tmp := project.CurrentLink + ".next"
+	if err := fs.Remove(tmp); err != nil && !errors.Is(err, iofs.ErrNotExist) {
+		return err
+	}
+	if err := fs.Symlink(project.ReleaseDir(release), tmp); err != nil {
+		return err
+	}
+	return fs.Rename(tmp, project.CurrentLink)
+}
+
+// restoreRelease activates an existing release and restarts the services it
+// contains. Restart failures are only logged because the release is already live.
+func restoreRelease(ctx context.Context, exec RemoteExecutor, fs RemoteFileSystem, strategies []DeploymentStrategy, project domain.Project, release string) error {
+	if err := activateRelease(fs, project, release); err != nil {
+		return err
+	}
+	if strategy := detectStrategy(ctx, strategies, fs, exec, project); strategy != nil {
+		if err := strategy.Restart(ctx, project, withEnv(exec, project.Env)); err != nil {
+			log.Printf("WARNING: failed to restart %s after activating release %s: %v", project.Name, release, err)
+		}
+	}
+	return nil
+}
+
+// removeRelease deletes a release directory.
+func removeRelease(ctx context.Context, exec RemoteExecutor, project domain.Project, release string) error {
+	_, err := exec.Run(ctx, "rm -rf "+shell.Escape(project.ReleaseDir(release)))
+	return err
+}
+
+// pruneReleases removes the oldest releases beyond keep, never touching the active one.
+func pruneReleases(ctx context.Context, exec RemoteExecutor, fs RemoteFileSystem, project domain.Project, keep int) error {
+	releases, err := listReleases(fs, project)
+	if err != nil {
+		return err
+	}
+	if len(releases) <= keep {
+		return nil
+	}
+	active, err := currentRelease(ctx, exec, project)
+	if err != nil {
+		return err
+	}
+	for _, release := range releases[:len(releases)-keep] {
+		if release == active {
+			continue
+		}
+		if err := removeRelease(ctx, exec, project, release); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// detectStrategy returns the strategy named by the project, or else the first
+// strategy whose detection, probing through fs and exec, matches.
+func detectStrategy(ctx context.Context, strategies []DeploymentStrategy, fs RemoteFileSystem, exec RemoteExecutor, project domain.Project) DeploymentStrategy {
+	if project.Strategy != "" {
+		for _, st := range strategies {
+			if st.Name() == project.Strategy {
+				return st
+			}
+		}
+		return nil
+	}
+	for _, st := range strategies {
+		ok, derr := st.Detect(ctx, fs, project, exec)
+		if derr != nil {
+			continue
+		}
+		if ok {
+			return st
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"github.com/dadyutenga/git-engine/internal/shared/shell"
+)
+
+var commitPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+// checkoutRevision fetches the requested revision into the project's source
+// checkout, verifies it is reachable from origin and checks it out. It
+// returns the resolved commit SHA.
+func checkoutRevision(ctx context.Context, exec RemoteExecutor, project domain.Project) (string, error) {
+	dir := shell.Escape(project.SourceDir)
+
+	var sha string
+	switch {
+	case project.Commit != "":
+		if !commitPattern.MatchString(project.Commit) {
+			return "", fmt.Errorf("invalid commit %q", project.Commit)
+		}
+		if _, err := exec.Run(Retryable(ctx), fmt.Sprintf("cd %s && git fetch --prune --tags origin", dir)); err != nil {
+			return "", err
+		}
+		commit := shell.Escape(project.Commit + "^{commit}")
+		out, err := exec.Run(Retryable(ctx), fmt.Sprintf("cd %s && git rev-parse --verify --quiet %s && git for-each-ref --count=1 --contains %s refs/remotes/origin refs/tags || true", dir, commit, commit))
+		if err != nil {
+			return "", err
+		}
+		lines := strings.Split(strings.TrimSpace(out.Stdout), "\n")
+		if len(lines) < 2 {
+			return "", fmt.Errorf("%w: commit %s", domain.ErrRefNotReachable, project.Commit)
+		}
+		sha = strings.TrimSpace(lines[0])
+	case project.Ref != "":
+		resolved, err := lsRemote(ctx, exec, dir, project.Ref)
+		if err != nil {
+			return "", err
+		}
+		if _, err := exec.Run(Retryable(ctx), fmt.Sprintf("cd %s && git fetch origin %s", dir, shell.Escape(project.Ref))); err != nil {
+			return "", err
+		}
+		sha = resolved
+	default:
+		resolved, err := lsRemote(ctx, exec, dir, "refs/heads/"+project.Branch)
+		if err != nil {
+			return "", err
+		}
+		if _, err := exec.Run(Retryable(ctx), fmt.Sprintf("cd %s && git fetch origin %s", dir, shell.Escape(project.Branch))); err != nil {
+			return "", err
+		}
+		sha = resolved
+	}
+
+	if _, err := exec.Run(ctx, fmt.Sprintf("cd %s && git reset --hard %s", dir, shell.Escape(sha))); err != nil {
+		return "", err
+	}
+	return sha, nil
+}
+
+// lsRemote resolves a ref on origin to the commit it points at. Only the tag
+// refs/tags/<ref>, peeled to its commit when annotated, or the branch
+// refs/heads/<ref> match, besides a full ref name; a ref naming both a tag and
+// a branch is ambiguous.
+func lsRemote(ctx context.Context, exec RemoteExecutor, dir, ref string) (string, error) {
+	out, err := exec.Run(Retryable(ctx), fmt.Sprintf("cd %s && git ls-remote origin %s %s", dir, shell.Escape(ref), shell.Escape(ref+"^{}")))
+	if err != nil {
+		return "", err
+	}
+	refs := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(out.Stdout), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			refs[fields[1]] = fields[0]
+		}
+	}
+	tag, ok := refs["refs/tags/"+ref+"^{}"]
+	if !ok {
+		tag = refs["refs/tags/"+ref]
+	}
+	branch := refs["refs/heads/"+ref]
+	switch {
+	case tag != "" && branch != "":
+		return "", fmt.Errorf("ref %s is ambiguous: it names both a tag and a branch; use refs/tags/%s or a commit", ref, ref)
+	case tag != "":
+		return tag, nil
+	case branch != "":
+		return branch, nil
+	case refs[ref+"^{}"] != "":
+		return refs[ref+"^{}"], nil
+	case refs[ref] != "":
+		return refs[ref], nil
+	}
+	return "", fmt.Errorf("%w: %s", domain.ErrRefNotReachable, ref)
+}
@@ -0,0 +1,74 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dadyutenga/git-engine/internal/domain"
+)
+
+func TestCheckoutRevisionResolvesRefs(t *testing.T) {
+	const (
+		tagSHA    = "1111111111111111111111111111111111111111"
+		peeledSHA = "2222222222222222222222222222222222222222"
+		branchSHA = "3333333333333333333333333333333333333333"
+	)
+	tests := []struct {
+		name     string
+		ref      string
+		lsRemote string
+		want     string
+		wantErr  string
+	}{
+		{name: "branch", ref: "v1", lsRemote: branchSHA + "\trefs/heads/v1\n", want: branchSHA},
+		{name: "lightweight tag", ref: "v1", lsRemote: tagSHA + "\trefs/tags/v1\n", want: tagSHA},
+		{name: "annotated tag", ref: "v1", lsRemote: tagSHA + "\trefs/tags/v1\n" + peeledSHA + "\trefs/tags/v1^{}\n", want: peeledSHA},
+		{name: "tag and branch", ref: "v1", lsRemote: tagSHA + "\trefs/tags/v1\n" + branchSHA + "\trefs/heads/v1\n", wantErr: "ambiguous"},
+		{name: "full ref name", ref: "refs/tags/v1", lsRemote: tagSHA + "\trefs/tags/v1\n", want: tagSHA},
+		{name: "suffix of another ref", ref: "v1", lsRemote: branchSHA + "\trefs/heads/feature/v1\n", wantErr: "not reachable"},
+		{name: "missing", ref: "v1", wantErr: "not reachable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := domain.NewProject("shop")
+			project.Ref = tt.ref
+			exec := &serverExec{fs: newMemFS(), lsRemote: tt.lsRemote}
+
+			got, err := checkoutRevision(context.Background(), exec, project)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("checkoutRevision error = %v, want %q", err, tt.wantErr)
+				}
+				for _, cmd := range exec.commands {
+					if strings.Contains(cmd, "git reset") {
+						t.Errorf("checked out after a failed resolution: %s", cmd)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkoutRevision: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("commit = %s, want %s", got, tt.want)
+			}
+			if last := exec.commands[len(exec.commands)-1]; !strings.HasSuffix(last, "git reset --hard '"+tt.want+"'") {
+				t.Errorf("last command = %s, want a reset to %s", last, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckoutRevisionRejectsInvalidCommit(t *testing.T) {
+	project := domain.NewProject("shop")
+	project.Commit = "HEAD~1; rm -rf /"
+	exec := &serverExec{fs: newMemFS()}
+	if _, err := checkoutRevision(context.Background(), exec, project); err == nil || errors.Is(err, domain.ErrRefNotReachable) {
+		t.Fatalf("checkoutRevision error = %v, want an invalid commit error", err)
+	}
+	if len(exec.commands) > 0 {
+		t.Errorf("ran %q for an invalid commit", exec.commands)
+	}
+}
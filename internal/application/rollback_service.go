@@ -1,71 +1,300 @@
 package application
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"sort"
+	"log"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/dadyutenga/git-engine/internal/domain"
 	"github.com/dadyutenga/git-engine/internal/shared/shell"
 )
 
-// RollbackService restores applications from backups.
+// RollbackService restores applications to an earlier release or from backups.
 type RollbackService struct {
 	Exec       RemoteExecutor
 	FS         RemoteFileSystem
+	Lock       LockManager
 	Strategies []DeploymentStrategy
+	// Local runs hooks declared with local: true.
+	Local RemoteExecutor
+	// Actor is recorded in the history ledger as the initiator.
+	Actor domain.Actor
+	// BackupStores hold the backups that can be restored.
+	BackupStores []BackupStore
+	// Dumper restores the database dumps taken with backups.
+	Dumper DatabaseDumper
+
+	// transcript records the running rollback in the project log file.
+	transcript *transcript
 }
 
-// Rollback restores the specified or latest backup.
-func (s RollbackService) Rollback(projectName, backup string) (domain.RollbackResult, error) {
-	project := domain.NewProject(projectName)
+// Rollback re-points the current symlink at an earlier release.
+//
+// When release is empty the release preceding the active one is used. When
+// backup is set the archive is checked against its manifest and extracted
+// into a new release directory first, which allows restoring code whose
+// release directory has been pruned. The rollback holds the project lock, so
+// it never runs alongside a push. With withDB the database dump taken with
+// the backup, or with the newest backup of the release, is loaded before the
+// release goes live. The attempt is appended to the history ledger.
+func (s RollbackService) Rollback(ctx context.Context, project domain.Project, release, backup string, withDB bool) (domain.RollbackResult, error) {
 	now := time.Now()
-	result := domain.RollbackResult{ProjectName: project.Name, Timestamp: now}
+	result := domain.RollbackResult{ID: domain.NewDeploymentID(now), ProjectName: project.Name, Backup: backup, Timestamp: now}
 
-	files, err := s.FS.List(project.BackupDir)
+	acquired, err := s.Lock.Acquire(ctx, project)
 	if err != nil {
-		result.Message = "failed to list backups"
+		result.Message = "failed to acquire deployment lock"
 		return result, err
 	}
-	if len(files) == 0 {
-		result.Message = "no backups available"
-		return result, fmt.Errorf("no backups found for %s", project.Name)
+	if !acquired {
+		result.Message = "deployment lock unavailable"
+		return result, domain.ErrLockUnavailable
 	}
+	defer func() {
+		if err := s.Lock.Release(context.WithoutCancel(ctx), project); err != nil {
+			log.Printf("WARNING: failed to release lock for %s: %v", project.Name, err)
+		}
+	}()
 
-	sort.Strings(files)
-	chosen := backup
-	if chosen == "" {
-		chosen = files[len(files)-1]
+	s.transcript = newTranscript(s.FS, project, result.ID)
+	s.transcript.start(domain.OperationRollback, s.Actor, project.Env)
+	s.Exec = s.transcript.executor(s.Exec, "")
+	s.Local = s.transcript.executor(s.Local, "local")
+	result, err = s.rollback(ctx, project, release, backup, withDB, result)
+
+	entry := domain.HistoryEntry{
+		ID:              result.ID,
+		Operation:       domain.OperationRollback,
+		Project:         project.Name,
+		User:            s.Actor.User,
+		Hostname:        s.Actor.Hostname,
+		Ref:             result.Ref,
+		Commit:          result.Commit,
+		Release:         result.Restored,
+		PreviousRelease: result.Previous,
+		Strategy:        result.Strategy,
+		Backup:          backup,
+		StartedAt:       now.UTC(),
+		DurationMS:      time.Since(now).Milliseconds(),
+		Outcome:         domain.OutcomeSuccess,
+		Message:         result.Message,
+	}
+	if err != nil {
+		entry.Outcome = domain.OutcomeFailed
+		entry.Error = err.Error()
 	}
+	s.transcript.finish(domain.OperationRollback, entry.Outcome, entry.Message, err)
+	recordHistory(s.FS, project, entry)
+	return result, err
+}
 
-	if _, err := s.Exec.Run(fmt.Sprintf("find %s -mindepth 1 -delete", shell.Escape(project.DeployDir))); err != nil {
-		result.Message = "failed to clear deployment directory"
+func (s RollbackService) rollback(ctx context.Context, project domain.Project, release, backup string, withDB bool, result domain.RollbackResult) (domain.RollbackResult, error) {
+	now := result.Timestamp
+	active, err := currentRelease(ctx, s.Exec, project)
+	if err != nil {
+		result.Message = "failed to resolve current release"
 		return result, err
 	}
+	result.Previous = active
+	entries, err := HistoryService{FS: s.FS}.List(ctx, project, domain.HistoryFilter{})
+	if err != nil {
+		log.Printf("WARNING: failed to read the history of %s: %v", project.Name, err)
+	}
+	result.PreviousCommit = releaseEntry(entries, active).Commit
 
-	if _, err := s.Exec.Run(fmt.Sprintf("tar -xzf %s -C %s", shell.Escape(fmt.Sprintf("%s/%s", project.BackupDir, chosen)), shell.Escape(project.DeployDir))); err != nil {
-		result.Message = "failed to restore backup"
-		return result, err
+	chosen := release
+	if backup == "" {
+		releases, err := listReleases(s.FS, project)
+		if err != nil {
+			result.Message = "failed to list releases"
+			return result, err
+		}
+		if chosen == "" {
+			chosen = previousRelease(releases, active)
+			if chosen == "" {
+				result.Message = "no previous release available"
+				return result, fmt.Errorf("no release to roll back to for %s", project.Name)
+			}
+		} else if !slices.Contains(releases, chosen) {
+			result.Message = "release not found"
+			return result, fmt.Errorf("release %s not found for %s", chosen, project.Name)
+		}
+	}
+
+	// The database dump is looked up before anything live is touched.
+	var dump *domain.DatabaseDump
+	if withDB && backup == "" {
+		if dump, err = s.releaseDump(ctx, project, chosen); err != nil {
+			result.Message = "no database dump available"
+			return result, err
+		}
 	}
 
-	var strategy DeploymentStrategy
-	for _, st := range s.Strategies {
-		ok, derr := st.Detect(s.FS, project)
-		if derr != nil {
-			continue
+	// A backup is verified and extracted before anything live is touched.
+	// Only names found in the backup listing are accepted, so a backup
+	// cannot point outside the backup directory.
+	if backup != "" {
+		b, store, err := verifyBackup(ctx, s.BackupStores, s.Exec, project, backup)
+		if errors.Is(err, domain.ErrBackupNotFound) {
+			result.Message = "backup not found"
+			return result, err
 		}
-		if ok {
-			strategy = st
-			break
+		if err != nil {
+			result.Message = "backup failed verification"
+			return result, err
 		}
+		if withDB {
+			if b.Database == nil {
+				result.Message = "no database dump available"
+				return result, fmt.Errorf("backup %s has no database dump", backup)
+			}
+			dump = b.Database
+		}
+		result.Commit, result.Strategy = b.Commit, b.Strategy
+		result.Ref = releaseEntry(entries, b.Release).Ref
+		if b.HasManifest {
+			s.transcript.step("verified backup %s (sha256 %s)", backup, b.SHA256)
+		} else {
+			log.Printf("WARNING: backup %s of %s has no manifest and cannot be verified", backup, project.Name)
+		}
+		chosen, err = s.restoreBackup(ctx, project, store, b, now)
+		if err != nil {
+			result.Message = "failed to restore backup"
+			return result, err
+		}
+		s.transcript.step("restored backup %s into release %s", backup, chosen)
 	}
 
-	if strategy != nil {
-		_ = strategy.Restart(project, s.Exec)
+	runner := hookRunner{remote: s.Exec, local: s.Local}
+	hooks, err := runner.run(ctx, project, domain.HookPreRollback, project.DeployDir)
+	result.Hooks = append(result.Hooks, hooks...)
+	if err != nil {
+		if backup != "" {
+			s.discardRelease(ctx, project, chosen)
+		}
+		result.Message = "pre-rollback hook failed"
+		return result, err
 	}
 
+	if dump != nil {
+		if err := restoreDatabase(ctx, s.Dumper, s.Exec, s.FS, project.WithRelease(chosen), *dump); err != nil {
+			if backup != "" {
+				s.discardRelease(ctx, project, chosen)
+			}
+			result.Message = "failed to restore database"
+			return result, err
+		}
+		s.transcript.step("restored %s database from %s", dump.Engine, dump.File)
+	}
+
+	if err := restoreRelease(ctx, s.Exec, s.FS, s.Strategies, project, chosen); err != nil {
+		result.Message = "failed to activate release"
+		return result, err
+	}
+	s.transcript.step("activated release %s", chosen)
+
 	result.Success = true
 	result.Restored = chosen
+	if backup == "" {
+		deployed := releaseEntry(entries, chosen)
+		result.Commit, result.Ref, result.Strategy = deployed.Commit, deployed.Ref, deployed.Strategy
+	}
+	if result.Strategy == "" {
+		if strategy := detectStrategy(ctx, s.Strategies, s.FS, s.Exec, project.WithRelease(chosen)); strategy != nil {
+			result.Strategy = strategy.Name()
+		}
+	}
 	result.Message = fmt.Sprintf("rollback complete using %s", chosen)
+	if backup != "" {
+		result.Message = fmt.Sprintf("rollback complete using %s (release %s)", backup, chosen)
+	}
+	if dump != nil {
+		result.Message += fmt.Sprintf(" and database dump %s", dump.File)
+	}
+
+	hooks, err = runner.run(ctx, project, domain.HookPostRollback, project.DeployDir)
+	result.Hooks = append(result.Hooks, hooks...)
+	if err != nil {
+		result.Message += "; post-rollback hook failed"
+		return result, err
+	}
 	return result, nil
 }
+
+// restoreBackup restores a backup into a staging directory and only moves it
+// into the releases directory once the store restored it completely. When the
+// backup left paths out, the strategy's Deploy rebuilds them.
+func (s RollbackService) restoreBackup(ctx context.Context, project domain.Project, store BackupStore, backup domain.Backup, now time.Time) (string, error) {
+	release := domain.NewReleaseID(now)
+	target := project.WithRelease(release)
+	staging := filepath.Join(project.BaseDir, ".restore-"+release)
+	removeStaging := func() {
+		if _, err := s.Exec.Run(context.WithoutCancel(ctx), "rm -rf "+shell.Escape(staging)); err != nil {
+			log.Printf("WARNING: failed to remove staging directory %s: %v", staging, err)
+		}
+	}
+	if _, err := s.Exec.Run(ctx, fmt.Sprintf("rm -rf %[1]s && mkdir -p %[1]s", shell.Escape(staging))); err != nil {
+		return "", err
+	}
+	if err := store.Restore(ctx, project, s.Exec, backup, staging); err != nil {
+		removeStaging()
+		return "", err
+	}
+	if err := s.FS.Rename(staging, target.DeployDir); err != nil {
+		removeStaging()
+		return "", err
+	}
+	if err := linkShared(ctx, s.Exec, target); err != nil {
+		s.discardRelease(ctx, project, release)
+		return "", err
+	}
+	if len(backup.Excludes) > 0 {
+		strategy := detectStrategy(ctx, s.Strategies, s.FS, s.Exec, target)
+		if strategy == nil {
+			s.discardRelease(ctx, project, release)
+			return "", domain.ErrUnsupportedProject
+		}
+		if err := strategy.Deploy(ctx, target, withEnv(s.Exec, project.Env)); err != nil {
+			s.discardRelease(ctx, project, release)
+			return "", fmt.Errorf("rebuild %s: %w", strings.Join(backup.Excludes, ", "), err)
+		}
+	}
+	return release, nil
+}
+
+// releaseDump returns the database dump of the newest backup of release.
+func (s RollbackService) releaseDump(ctx context.Context, project domain.Project, release string) (*domain.DatabaseDump, error) {
+	backups, err := listBackups(ctx, s.BackupStores, project)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range backups {
+		if b.Release == release && b.Database != nil {
+			return b.Database, nil
+		}
+	}
+	return nil, fmt.Errorf("no backup of release %s has a database dump", release)
+}
+
+// discardRelease removes a restored release that never became active.
+func (s RollbackService) discardRelease(ctx context.Context, project domain.Project, release string) {
+	if err := removeRelease(context.WithoutCancel(ctx), s.Exec, project, release); err != nil {
+		log.Printf("WARNING: failed to remove release %s for %s: %v", release, project.Name, err)
+	}
+}
+
+// previousRelease returns the release sorted immediately before active, or
+// the newest release when active is unknown.
+func previousRelease(releases []string, active string) string {
+	for i := len(releases) - 1; i >= 0; i-- {
+		if active == "" || releases[i] < active {
+			return releases[i]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dadyutenga/git-engine/internal/domain"
+)
+
+func TestRollbackRejectsBusyLock(t *testing.T) {
+	project := domain.NewProject("shop")
+	server := newTestServer(project)
+	server.fs.Mkdir(project.ReleaseDir("20230101000000"), true)
+	rollback := server.rollbackService()
+	rollback.Lock = fakeLock{busy: true}
+
+	res, err := rollback.Rollback(context.Background(), project, "", "", false)
+	if !errors.Is(err, domain.ErrLockUnavailable) {
+		t.Fatalf("Rollback error = %v, want %v", err, domain.ErrLockUnavailable)
+	}
+	if res.Success || len(server.exec.commands) > 0 {
+		t.Errorf("rollback ran without the lock: %+v, commands %q", res, server.exec.commands)
+	}
+	if got := server.current(project); got != liveRelease {
+		t.Errorf("current release = %s, want %s", got, liveRelease)
+	}
+}
+
+func TestRollbackAcceptsOnlyListedNames(t *testing.T) {
+	project := domain.NewProject("shop")
+	tests := []struct {
+		name    string
+		release string
+		backup  string
+		wantErr string
+	}{
+		{name: "listed backup", backup: "shop-20230101000000.tar.gz"},
+		{name: "backup outside the backup directory", backup: "../../etc/passwd", wantErr: "backup not found"},
+		{name: "unlisted backup", backup: "shop-20220101000000.tar.gz", wantErr: "backup not found"},
+		{name: "listed release", release: "20230101000000"},
+		{name: "release outside the releases directory", release: "../shared", wantErr: "release ../shared not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(project)
+			server.fs.Mkdir(project.ReleaseDir("20230101000000"), true)
+			server.store.backups = []domain.Backup{{Name: "shop-20230101000000.tar.gz", Store: domain.BackupStoreTarball, Release: "20230101000000"}}
+
+			res, err := server.rollbackService().Rollback(context.Background(), project, tt.release, tt.backup, false)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Rollback error = %v, want %q", err, tt.wantErr)
+				}
+				if got := server.current(project); got != liveRelease {
+					t.Errorf("current release = %s, want %s", got, liveRelease)
+				}
+				if len(server.strategy.restarted) > 0 {
+					t.Errorf("restarted %v after a rejected rollback", server.strategy.restarted)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Rollback: %v", err)
+			}
+			if !res.Success || res.Previous != liveRelease {
+				t.Errorf("result = %+v", res)
+			}
+			if got := server.current(project); got != res.Restored {
+				t.Errorf("current release = %s, want %s", got, res.Restored)
+			}
+			if ok, _ := server.fs.Exists(project.ReleaseDir(res.Restored)); !ok {
+				t.Errorf("restored release %s does not exist", res.Restored)
+			}
+		})
+	}
+}
+
+func TestRollbackRecordsRestoredRelease(t *testing.T) {
+	project := domain.NewProject("shop")
+	server := newTestServer(project)
+	server.fs.Mkdir(project.ReleaseDir("20230101000000"), true)
+	recordHistory(server.fs, project, domain.HistoryEntry{
+		ID:        "20230101000000-abcdef",
+		Operation: domain.OperationPush,
+		Project:   project.Name,
+		Ref:       "v1.2.0",
+		Commit:    "0123456789abcdef0123456789abcdef01234567",
+		Release:   "20230101000000",
+		Strategy:  "node",
+		Outcome:   domain.OutcomeSuccess,
+	})
+
+	if _, err := server.rollbackService().Rollback(context.Background(), project, "", "", false); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	entries, err := HistoryService{FS: server.fs}.List(context.Background(), project, domain.HistoryFilter{Operation: domain.OperationRollback})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("history = %+v, %v", entries, err)
+	}
+	got := entries[0]
+	if got.Release != "20230101000000" || got.Ref != "v1.2.0" || got.Commit != "0123456789abcdef0123456789abcdef01234567" || got.Strategy != "node" {
+		t.Errorf("rollback entry = %+v, want the ref, commit and strategy of release 20230101000000", got)
+	}
+}
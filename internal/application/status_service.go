@@ -1,6 +1,7 @@
 package application
 
 import (
+	"context"
 	"time"
 
 	"github.com/dadyutenga/git-engine/internal/domain"
@@ -14,12 +15,11 @@ type StatusService struct {
 }
 
 // Status returns project status details.
-func (s StatusService) Status(projectName string) (domain.StatusResult, error) {
-	project := domain.NewProject(projectName)
+func (s StatusService) Status(ctx context.Context, project domain.Project) (domain.StatusResult, error) {
 	now := time.Now()
 	result := domain.StatusResult{ProjectName: project.Name, Timestamp: now}
 
-	exists, err := s.FS.Exists(project.DeployDir)
+	exists, err := s.FS.Exists(project.BaseDir)
 	if err != nil {
 		result.Message = "failed to check project path"
 		return result, err
@@ -31,12 +31,19 @@ func (s StatusService) Status(projectName string) (domain.StatusResult, error) {
 	}
 	result.Exists = true
 
-	for _, st := range s.Strategies {
-		ok, derr := st.Detect(s.FS, project)
-		if derr != nil || !ok {
-			continue
-		}
-		running, serr := st.Status(project, s.Exec)
+	release, err := currentRelease(ctx, s.Exec, project)
+	if err != nil {
+		result.Message = "failed to resolve current release"
+		return result, err
+	}
+	if release == "" {
+		result.Message = "no active release"
+		return result, nil
+	}
+	result.Release = release
+
+	if st := detectStrategy(ctx, s.Strategies, s.FS, s.Exec, project); st != nil {
+		running, serr := st.Status(ctx, project, withEnv(s.Exec, project.Env))
 		result.Running = running
 		result.Strategy = st.Name()
 		result.Message = "status retrieved"
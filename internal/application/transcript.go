@@ -0,0 +1,176 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dadyutenga/git-engine/internal/domain"
+)
+
+// transcript tees every command of an operation, with its output, exit
+// status and timing, into the project log file. Each line starts with the
+// deploy ID so that "logs -deploy" can select one operation.
+//
+// Lines are appended after every command; while the log directory does not
+// exist yet (during init) they are kept and written with the next step.
+type transcript struct {
+	fs      RemoteFileSystem
+	path    string
+	prefix  string
+	project string
+	// envPrefix is the export statement withEnv adds to commands; it is left
+	// out of the transcript so variable values are not logged.
+	envPrefix string
+
+	mu      sync.Mutex
+	pending bytes.Buffer
+	failed  error
+}
+
+func newTranscript(fsys RemoteFileSystem, project domain.Project, id string) *transcript {
+	return &transcript{fs: fsys, path: project.LogFile, prefix: transcriptPrefix(id), project: project.Name, envPrefix: envPrefix(project.Env)}
+}
+
+// transcriptPrefix starts every transcript line of the operation id.
+func transcriptPrefix(id string) string {
+	return "[" + id + "] "
+}
+
+// start records who started operation and which environment variables are
+// exported to its commands.
+func (t *transcript) start(operation string, actor domain.Actor, env map[string]string) {
+	t.logf("%s %s started by %s@%s", operation, t.project, actor.User, actor.Hostname)
+	if len(env) > 0 {
+		keys := make([]string, 0, len(env))
+		for k := range env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		t.logf("environment: %s", strings.Join(keys, " "))
+	}
+	t.flush()
+}
+
+// finish records the outcome of the operation and writes what is left.
+func (t *transcript) finish(operation, outcome, message string, err error) {
+	t.logf("%s finished: %s: %s", operation, outcome, message)
+	if err != nil {
+		t.write("  error| ", err.Error())
+	}
+	t.flush()
+	t.mu.Lock()
+	failed := t.failed
+	t.mu.Unlock()
+	if failed != nil {
+		log.Printf("WARNING: failed to write the transcript of %s to %s: %v", t.project, t.path, failed)
+	}
+}
+
+// executor wraps exec so its commands are recorded; label marks where they
+// ran, e.g. "local". A nil exec stays nil.
+func (t *transcript) executor(exec RemoteExecutor, label string) RemoteExecutor {
+	if exec == nil {
+		return nil
+	}
+	return transcriptExecutor{exec: exec, t: t, label: label}
+}
+
+// step records a step that is not a command, such as a file system change.
+// It is a no-op on a nil transcript.
+func (t *transcript) step(format string, args ...any) {
+	if t == nil {
+		return
+	}
+	t.logf(format, args...)
+	t.flush()
+}
+
+func (t *transcript) logf(format string, args ...any) {
+	t.write(time.Now().UTC().Format(time.RFC3339)+" ", fmt.Sprintf(format, args...))
+}
+
+// write adds text line by line, each line after lead.
+func (t *transcript) write(lead, text string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		t.pending.WriteString(t.prefix + lead + line + "\n")
+	}
+}
+
+// record logs a finished command.
+func (t *transcript) record(result domain.CommandResult, err error) {
+	if result.Stdout != "" {
+		t.write("  stdout| ", result.Stdout)
+	}
+	if result.Stderr != "" {
+		t.write("  stderr| ", result.Stderr)
+	}
+	switch status := domain.ExitStatus(err); {
+	case err == nil:
+		t.logf("exit 0 after %s", result.Duration.Round(time.Millisecond))
+	case status >= 0:
+		t.logf("exit %d after %s", status, result.Duration.Round(time.Millisecond))
+	default:
+		t.logf("failed after %s: %v", result.Duration.Round(time.Millisecond), err)
+	}
+	t.flush()
+}
+
+// flush appends the pending lines to the log file, keeping them for the next
+// attempt when that fails.
+func (t *transcript) flush() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pending.Len() == 0 {
+		return
+	}
+	if err := t.fs.AppendFile(t.path, t.pending.Bytes()); err != nil {
+		t.failed = err
+		return
+	}
+	t.failed = nil
+	t.pending.Reset()
+}
+
+// transcriptExecutor records the commands it runs in a transcript.
+type transcriptExecutor struct {
+	exec  RemoteExecutor
+	t     *transcript
+	label string
+}
+
+func (e transcriptExecutor) Run(ctx context.Context, command string) (domain.CommandResult, error) {
+	e.begin(command)
+	start := time.Now()
+	result, err := e.exec.Run(ctx, command)
+	if result.Duration == 0 {
+		result.Duration = time.Since(start)
+	}
+	e.t.record(result, err)
+	return result, err
+}
+
+func (e transcriptExecutor) RunStream(ctx context.Context, command string, writer io.Writer) error {
+	e.begin(command)
+	start := time.Now()
+	err := e.exec.RunStream(ctx, command, writer)
+	e.t.record(domain.CommandResult{Duration: time.Since(start)}, err)
+	return err
+}
+
+func (e transcriptExecutor) begin(command string) {
+	command = strings.TrimPrefix(command, e.t.envPrefix)
+	if e.label != "" {
+		e.t.logf("%s$ %s", e.label, command)
+		return
+	}
+	e.t.logf("$ %s", command)
+}
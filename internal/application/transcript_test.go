@@ -0,0 +1,141 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dadyutenga/git-engine/internal/domain"
+)
+
+// logFS keeps appended data in memory and fails appends while missing is set.
+type logFS struct {
+	RemoteFileSystem
+	data    strings.Builder
+	missing bool
+}
+
+func (f *logFS) AppendFile(path string, data []byte) error {
+	if f.missing {
+		return errors.New("no such file or directory")
+	}
+	f.data.Write(data)
+	return nil
+}
+
+// scriptedExecutor answers every command with result and err.
+type scriptedExecutor struct {
+	result domain.CommandResult
+	err    error
+}
+
+func (e scriptedExecutor) Run(ctx context.Context, command string) (domain.CommandResult, error) {
+	return e.result, e.err
+}
+
+func (e scriptedExecutor) RunStream(ctx context.Context, command string, writer io.Writer) error {
+	return e.err
+}
+
+func TestTranscriptRecordsCommands(t *testing.T) {
+	env := map[string]string{"API_KEY": "s3cret"}
+	tests := []struct {
+		name    string
+		exec    scriptedExecutor
+		label   string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "output and exit status",
+			exec:    scriptedExecutor{result: domain.CommandResult{Stdout: "one\ntwo\n", Stderr: "warn"}},
+			want:    []string{"$ npm install", "  stdout| one", "  stdout| two", "  stderr| warn", "exit 0 after"},
+			notWant: []string{"s3cret"},
+		},
+		{
+			name:  "remote failure",
+			exec:  scriptedExecutor{err: &domain.RemoteCommandError{Result: domain.CommandResult{ExitStatus: 2}, Err: errors.New("exit 2")}},
+			label: "local",
+			want:  []string{"local$ npm install", "exit 2 after"},
+		},
+		{
+			name: "connection failure",
+			exec: scriptedExecutor{err: errors.New("ssh connection lost")},
+			want: []string{"failed after", "ssh connection lost"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := &logFS{}
+			project := domain.NewProject("shop")
+			project.Env = env
+			tr := newTranscript(fsys, project, "20240601120000-abcdef")
+			exec := withEnv(tr.executor(tt.exec, tt.label), env)
+			_, _ = exec.Run(context.Background(), "npm install")
+
+			got := fsys.data.String()
+			for _, line := range strings.Split(strings.TrimSpace(got), "\n") {
+				if !strings.HasPrefix(line, "[20240601120000-abcdef] ") {
+					t.Errorf("line %q lacks the deploy ID", line)
+				}
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("transcript lacks %q:\n%s", want, got)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("transcript contains %q:\n%s", notWant, got)
+				}
+			}
+		})
+	}
+}
+
+func TestTranscriptKeepsLinesUntilLogExists(t *testing.T) {
+	fsys := &logFS{missing: true}
+	tr := newTranscript(fsys, domain.NewProject("shop"), "id")
+	tr.step("created directories")
+	if fsys.data.Len() != 0 || tr.failed == nil {
+		t.Fatalf("expected the first append to fail and keep its lines")
+	}
+	fsys.missing = false
+	tr.step("installed hook")
+	got := fsys.data.String()
+	if !strings.Contains(got, "created directories") || !strings.Contains(got, "installed hook") || tr.failed != nil {
+		t.Errorf("pending lines were not written with the next step:\n%s", got)
+	}
+}
+
+// gitPusher pushes by running git push through the local executor.
+type gitPusher struct{}
+
+func (gitPusher) Push(ctx context.Context, project domain.Project, local RemoteExecutor) error {
+	_, err := local.Run(ctx, "git push origin "+project.Branch)
+	return err
+}
+
+func TestDeployTranscriptRecordsProbes(t *testing.T) {
+	project := domain.NewProject("shop")
+	project.HealthChecks = []domain.HealthCheck{{Type: "http", URL: "http://127.0.0.1:3000/health"}}
+	server := newTestServer(project)
+	deploy := server.deployService(healthFunc(func(ctx context.Context, project domain.Project, check domain.HealthCheck, exec RemoteExecutor) error {
+		_, err := exec.Run(ctx, "curl -sS "+check.URL)
+		return err
+	}))
+	deploy.Local = &serverExec{fs: newMemFS()}
+	deploy.Source = gitPusher{}
+
+	if _, err := deploy.Deploy(context.Background(), project); err != nil {
+		t.Fatalf("Deploy: %v", err)
+	}
+	got := string(server.fs.files[project.LogFile])
+	for _, want := range []string{"local$ git push origin main", "$ test -f " + project.ReleasesDir, "$ curl -sS http://127.0.0.1:3000/health"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("transcript lacks %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package domain
+
+// ArtifactBuild configures artifact mode: the project is built on the machine
+// running the CLI and only the packaged output is shipped to the server.
+type ArtifactBuild struct {
+	// Command builds the project inside a clean export of the revision.
+	Command string
+	// Output is the directory packaged after the build, relative to the export
+	// root; empty packages the whole export.
+	Output string
+	// Image runs the build in a local docker container instead of the host.
+	Image string
+}
+
+// Artifact is a packaged build ready to be uploaded.
+type Artifact struct {
+	// Path is the local gzipped tarball.
+	Path   string
+	SHA256 string
+	Size   int64
+	Commit string
+}
@@ -0,0 +1,148 @@
+package domain
+
+import (
+	"errors"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrBackupNotFound is returned when a named backup is not in the backup directory.
+var ErrBackupNotFound = errors.New("backup not found")
+
+// Backup describes an archive of a release taken before it was replaced. It
+// is stored as JSON next to the archive, as its manifest.
+type Backup struct {
+	Name string `json:"name"`
+	// Store is the backup store holding the backup.
+	Store  string `json:"store,omitempty"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+	// Release is the release the archive captured, when known.
+	Release string `json:"release,omitempty"`
+	// Commit is the commit deployed in Release, when known.
+	Commit string `json:"commit,omitempty"`
+	// Strategy is the strategy that deployed Release, when known.
+	Strategy string `json:"strategy,omitempty"`
+	// Compression is the format of the archive, one of the Compression constants.
+	Compression string `json:"compression"`
+	// Excludes are the tar patterns left out of the archive; the strategy
+	// has to rebuild them when the backup is restored.
+	Excludes []string `json:"excludes,omitempty"`
+	// Database is the dump of the project's database taken with the backup.
+	Database  *DatabaseDump `json:"database,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	// HasManifest reports whether the manifest was found; archives taken
+	// before manifests existed cannot be verified.
+	HasManifest bool `json:"-"`
+}
+
+// Backup stores.
+const (
+	// BackupStoreTarball keeps one compressed archive per backup.
+	BackupStoreTarball = "tarball"
+	// BackupStoreSnapshot keeps a directory tree per backup, hardlinking
+	// files unchanged since the previous snapshot.
+	BackupStoreSnapshot = "snapshot"
+)
+
+// Backup compression formats.
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+	CompressionNone = "none"
+)
+
+// backupExtensions maps compression formats to archive file extensions.
+var backupExtensions = map[string]string{
+	CompressionGzip: ".tgz",
+	CompressionZstd: ".tar.zst",
+	CompressionNone: ".tar",
+}
+
+// BackupExtension returns the file extension of archives in compression.
+func BackupExtension(compression string) string {
+	return backupExtensions[compression]
+}
+
+// BackupCompression infers the compression of an archive from its name.
+func BackupCompression(name string) (string, bool) {
+	for compression, ext := range backupExtensions {
+		if strings.HasSuffix(name, ext) {
+			return compression, true
+		}
+	}
+	return "", false
+}
+
+// BackupPolicy configures how release backups are taken and kept.
+type BackupPolicy struct {
+	Retention BackupRetention
+	// Store names the backup store new backups are written to.
+	Store string
+	// Compression applies to tarball backups.
+	Compression string
+	// Include are globs re-including paths skipped by the strategy's default
+	// excludes.
+	Include []string
+	// Exclude are globs left out of the archive in addition to the defaults.
+	Exclude []string
+	// Database dumps the database configured in the release's .env with
+	// every backup, before the next release migrates it.
+	Database bool
+}
+
+// DefaultBackupPolicy applies when a project does not configure backups.
+var DefaultBackupPolicy = BackupPolicy{Retention: DefaultBackupRetention, Store: BackupStoreTarball, Compression: CompressionGzip}
+
+// Excludes combines the strategy defaults with the policy's globs.
+func (p BackupPolicy) Excludes(defaults []string) []string {
+	var excludes []string
+	for _, d := range defaults {
+		included := slices.ContainsFunc(p.Include, func(glob string) bool {
+			matched, _ := path.Match(glob, d)
+			return matched || glob == d
+		})
+		if !included {
+			excludes = append(excludes, d)
+		}
+	}
+	for _, e := range p.Exclude {
+		if !slices.Contains(excludes, e) {
+			excludes = append(excludes, e)
+		}
+	}
+	return excludes
+}
+
+// BackupRetention bounds the backups kept for a project. Zero fields do not
+// limit anything.
+type BackupRetention struct {
+	// KeepLast keeps at most this many backups.
+	KeepLast int
+	// MaxAge removes backups older than this.
+	MaxAge time.Duration
+	// MaxSize bounds the total size of the backups in bytes.
+	MaxSize int64
+}
+
+// DefaultBackupRetention applies when a project does not configure retention.
+var DefaultBackupRetention = BackupRetention{KeepLast: 10}
+
+// Expired returns the backups that fall outside the policy, given backups
+// sorted newest first. The newest backup is always kept.
+func (r BackupRetention) Expired(backups []Backup, now time.Time) []Backup {
+	var expired []Backup
+	var total int64
+	for i, b := range backups {
+		if i > 0 && (r.KeepLast > 0 && i >= r.KeepLast ||
+			r.MaxAge > 0 && now.Sub(b.CreatedAt) > r.MaxAge ||
+			r.MaxSize > 0 && total+b.Size > r.MaxSize) {
+			expired = append(expired, b)
+			continue
+		}
+		total += b.Size
+	}
+	return expired
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBackupRetentionExpired(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	backup := func(name string, age time.Duration, size int64) Backup {
+		return Backup{Name: name, CreatedAt: now.Add(-age), Size: size}
+	}
+	// Newest first, as the stores list them.
+	backups := []Backup{
+		backup("b4", time.Hour, 100),
+		backup("b3", 2*24*time.Hour, 100),
+		backup("b2", 5*24*time.Hour, 100),
+		backup("b1", 10*24*time.Hour, 100),
+	}
+	tests := []struct {
+		name      string
+		retention BackupRetention
+		backups   []Backup
+		want      []string
+	}{
+		{"unlimited", BackupRetention{}, backups, nil},
+		{"keep last", BackupRetention{KeepLast: 2}, backups, []string{"b2", "b1"}},
+		{"keep last above count", BackupRetention{KeepLast: 10}, backups, nil},
+		{"max age", BackupRetention{MaxAge: 3 * 24 * time.Hour}, backups, []string{"b2", "b1"}},
+		{"max size", BackupRetention{MaxSize: 250}, backups, []string{"b2", "b1"}},
+		{"combined limits", BackupRetention{KeepLast: 3, MaxAge: 4 * 24 * time.Hour, MaxSize: 1000}, backups, []string{"b2", "b1"}},
+		{"newest kept despite age", BackupRetention{MaxAge: time.Minute}, backups, []string{"b3", "b2", "b1"}},
+		{"newest kept despite size", BackupRetention{MaxSize: 10}, backups, []string{"b3", "b2", "b1"}},
+		{"single backup kept", BackupRetention{KeepLast: 1, MaxAge: time.Minute, MaxSize: 1}, backups[:1], nil},
+		{"no backups", BackupRetention{KeepLast: 1}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, b := range tt.retention.Expired(tt.backups, now) {
+				got = append(got, b.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackupPolicyExcludes(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   BackupPolicy
+		defaults []string
+		want     []string
+	}{
+		{"defaults only", BackupPolicy{}, []string{"node_modules"}, []string{"node_modules"}},
+		{"no defaults", BackupPolicy{Exclude: []string{"*.log"}}, nil, []string{"*.log"}},
+		{"defaults then policy", BackupPolicy{Exclude: []string{"storage/logs", "*.log"}}, []string{"vendor"}, []string{"vendor", "storage/logs", "*.log"}},
+		{"include drops a default", BackupPolicy{Include: []string{"vendor"}}, []string{"vendor", "node_modules"}, []string{"node_modules"}},
+		{"include glob drops defaults", BackupPolicy{Include: []string{"*"}}, []string{"vendor", "node_modules"}, nil},
+		{"include leaves policy excludes", BackupPolicy{Include: []string{"vendor"}, Exclude: []string{"vendor"}}, []string{"vendor"}, []string{"vendor"}},
+		{"duplicates removed", BackupPolicy{Exclude: []string{"node_modules"}}, []string{"node_modules"}, []string{"node_modules"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Excludes(tt.defaults); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Excludes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
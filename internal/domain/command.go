@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CommandResult is the outcome of a command run on a target host.
+type CommandResult struct {
+	Command string
+	Stdout  string
+	Stderr  string
+	// ExitStatus is -1 when the command did not exit on its own, e.g. after a
+	// timeout or a lost connection.
+	ExitStatus int
+	Duration   time.Duration
+}
+
+// Output returns stdout followed by stderr.
+func (r CommandResult) Output() string {
+	return r.Stdout + r.Stderr
+}
+
+// StderrTail returns up to the last n non-empty lines of stderr.
+func (r CommandResult) StderrTail(n int) []string {
+	var lines []string
+	for _, line := range strings.Split(r.Stderr, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// RemoteCommandError reports a command that failed on a target host.
+type RemoteCommandError struct {
+	Result CommandResult
+	// Err is the underlying error reported by the transport.
+	Err error
+}
+
+func (e *RemoteCommandError) Error() string {
+	if e.Result.ExitStatus < 0 {
+		return fmt.Sprintf("command failed: %v", e.Err)
+	}
+	msg := fmt.Sprintf("command exited with status %d", e.Result.ExitStatus)
+	if tail := e.Result.StderrTail(1); len(tail) > 0 {
+		msg += ": " + strings.TrimSpace(tail[0])
+	}
+	return msg
+}
+
+func (e *RemoteCommandError) Unwrap() error {
+	return e.Err
+}
+
+// ExitStatus returns the exit status carried by a *RemoteCommandError in err,
+// or -1 when err does not come from a command that exited on its own.
+func ExitStatus(err error) int {
+	var cmdErr *RemoteCommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.Result.ExitStatus
+	}
+	return -1
+}
@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoDatabase is returned when a project's .env configures no database.
+var ErrNoDatabase = errors.New("no database configured")
+
+// Database engines that can be dumped.
+const (
+	DatabaseMySQL    = "mysql"
+	DatabasePostgres = "postgres"
+	DatabaseSQLite   = "sqlite"
+)
+
+// Database holds the connection settings of a project's database. For SQLite
+// Name is the path of the database file.
+type Database struct {
+	Engine   string
+	Host     string
+	Port     string
+	Name     string
+	User     string
+	Password string
+}
+
+// DatabaseDump describes a compressed database dump kept in the backup
+// directory next to a backup.
+type DatabaseDump struct {
+	File   string `json:"file"`
+	Engine string `json:"engine"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// databaseEngines maps Laravel connection names and DATABASE_URL schemes to
+// engines.
+var databaseEngines = map[string]string{
+	"mysql":      DatabaseMySQL,
+	"mariadb":    DatabaseMySQL,
+	"pgsql":      DatabasePostgres,
+	"postgres":   DatabasePostgres,
+	"postgresql": DatabasePostgres,
+	"sqlite":     DatabaseSQLite,
+}
+
+// ParseEnvFile reads the KEY=value lines of a .env file. Comments, blank
+// lines and an "export" prefix are ignored, and values may be quoted.
+func ParseEnvFile(data []byte) map[string]string {
+	env := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if n := len(value); n >= 2 && (value[0] == '"' || value[0] == '\'') && value[n-1] == value[0] {
+			value = value[1 : n-1]
+		} else if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		env[strings.TrimSpace(key)] = value
+	}
+	return env
+}
+
+// DatabaseFromEnv returns the database configured by DATABASE_URL, as Python
+// projects commonly do, or by Laravel's DB_* variables. Relative SQLite paths
+// are resolved against dir.
+func DatabaseFromEnv(env map[string]string, dir string) (Database, error) {
+	var db Database
+	if raw := env["DATABASE_URL"]; raw != "" {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return Database{}, fmt.Errorf("invalid DATABASE_URL: %w", err)
+		}
+		db.Engine = databaseEngines[u.Scheme]
+		db.Host, db.Port = u.Hostname(), u.Port()
+		db.User = u.User.Username()
+		db.Password, _ = u.User.Password()
+		db.Name = strings.TrimPrefix(u.Path, "/")
+		if db.Engine == "" {
+			return Database{}, fmt.Errorf("unsupported database %q in DATABASE_URL", u.Scheme)
+		}
+	} else {
+		connection := env["DB_CONNECTION"]
+		if connection == "" {
+			return Database{}, ErrNoDatabase
+		}
+		db = Database{Engine: databaseEngines[connection], Host: env["DB_HOST"], Port: env["DB_PORT"], Name: env["DB_DATABASE"], User: env["DB_USERNAME"], Password: env["DB_PASSWORD"]}
+		if db.Engine == "" {
+			return Database{}, fmt.Errorf("unsupported DB_CONNECTION %q", connection)
+		}
+		if db.Engine == DatabaseSQLite && db.Name == "" {
+			db.Name = "database/database.sqlite"
+		}
+	}
+	if db.Name == "" {
+		return Database{}, fmt.Errorf("%w: the database name is empty", ErrNoDatabase)
+	}
+	if db.Engine == DatabaseSQLite && !filepath.IsAbs(db.Name) {
+		db.Name = filepath.Join(dir, db.Name)
+	}
+	return db, nil
+}
@@ -4,6 +4,8 @@ import "time"
 
 // DeploymentResult captures the outcome of a deployment execution.
 type DeploymentResult struct {
+	// ID identifies this deployment in the history ledger and transcript.
+	ID          string
 	ProjectName string
 	Success     bool
 	Status      string
@@ -11,10 +13,37 @@ type DeploymentResult struct {
 	LogFile     string
 	Timestamp   time.Time
 	Details     map[string]string
+	Hooks       []HookResult
+}
+
+// HostDeploymentResult pairs a host with the outcome of deploying to it.
+type HostDeploymentResult struct {
+	Host   string
+	Result DeploymentResult
+	Err    error
+}
+
+// DeploymentSummary aggregates the per-host results of a multi-host deployment.
+type DeploymentSummary struct {
+	ProjectName string
+	Hosts       []HostDeploymentResult
+	Succeeded   int
+	Failed      int
+	Skipped     int
+	RolledBack  int
+	// Halted is set when the rollout stopped after exceeding its failure threshold.
+	Halted    bool
+	Timestamp time.Time
+}
+
+// Success reports whether every host deployed successfully.
+func (s DeploymentSummary) Success() bool {
+	return s.Failed == 0 && s.Skipped == 0 && s.RolledBack == 0 && s.Succeeded > 0
 }
 
 // InitResult represents the output of an init operation.
 type InitResult struct {
+	ID        string
 	Project   Project
 	Success   bool
 	Message   string
@@ -23,11 +52,25 @@ type InitResult struct {
 
 // RollbackResult represents the outcome of a rollback.
 type RollbackResult struct {
+	ID          string
 	ProjectName string
 	Success     bool
 	Restored    string
-	Message     string
-	Timestamp   time.Time
+	// Commit is the commit deployed in Restored, when known.
+	Commit string
+	// Ref is the revision Restored was deployed from, when known.
+	Ref string
+	// Strategy is the deployment strategy of Restored.
+	Strategy string
+	// Backup is the backup Restored was extracted from, if any.
+	Backup string
+	// Previous is the release that was active before the rollback.
+	Previous string
+	// PreviousCommit is the commit deployed in Previous, when known.
+	PreviousCommit string
+	Message        string
+	Timestamp      time.Time
+	Hooks          []HookResult
 }
 
 // StatusResult describes the remote state of an application.
@@ -36,6 +79,7 @@ type StatusResult struct {
 	Exists      bool
 	Running     bool
 	Strategy    string
+	Release     string
 	Message     string
 	Timestamp   time.Time
 }
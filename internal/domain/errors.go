@@ -9,4 +9,10 @@ var (
 	ErrLockUnavailable = errors.New("deployment lock unavailable")
 	// ErrUnsupportedProject denotes an unknown project type.
 	ErrUnsupportedProject = errors.New("unsupported project type")
+	// ErrRefNotReachable is returned when a requested revision is not reachable from the remote.
+	ErrRefNotReachable = errors.New("revision not reachable from remote")
+	// ErrChecksumMismatch indicates an uploaded file differs from the local one.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrHealthCheckFailed signals that a release did not pass its health checks.
+	ErrHealthCheckFailed = errors.New("health check failed")
 )
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Health check types supported after a deployment.
+const (
+	HealthCheckHTTP    = "http"
+	HealthCheckTCP     = "tcp"
+	HealthCheckCommand = "command"
+)
+
+// DefaultHealthRetries is the number of retries of a check that sets none.
+const DefaultHealthRetries = 3
+
+// HealthCheck describes a probe that must pass before a release is considered healthy.
+type HealthCheck struct {
+	Type string
+	// URL, ExpectStatus and ExpectBody configure HTTP checks. ExpectBody is a substring match.
+	URL          string
+	ExpectStatus int
+	ExpectBody   string
+	// Host and Port configure TCP checks.
+	Host string
+	Port int
+	// Command is run in the project directory for command checks.
+	Command string
+	// Retries is the number of additional attempts after the first failure;
+	// 0 fails on the first one.
+	Retries int
+	// Interval is the delay before the first retry (default 2s); it doubles after every attempt.
+	Interval time.Duration
+	// Timeout bounds a single attempt.
+	Timeout time.Duration
+}
+
+// Describe returns a short human readable label for the check.
+func (h HealthCheck) Describe() string {
+	switch h.Type {
+	case HealthCheckHTTP:
+		return "http " + h.URL
+	case HealthCheckTCP:
+		return fmt.Sprintf("tcp %s:%d", h.Host, h.Port)
+	default:
+		return h.Type + " " + h.Command
+	}
+}
@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Operations recorded in the history ledger.
+const (
+	OperationPush     = "push"
+	OperationRollback = "rollback"
+	// OperationInit only appears in transcripts.
+	OperationInit = "init"
+)
+
+// Outcomes recorded in the history ledger.
+const (
+	OutcomeSuccess    = "success"
+	OutcomeFailed     = "failed"
+	OutcomeRolledBack = "rolled_back"
+)
+
+// Actor identifies who started an operation.
+type Actor struct {
+	User     string
+	Hostname string
+}
+
+// HistoryEntry is one line of a project's deployment history ledger.
+type HistoryEntry struct {
+	ID              string    `json:"id"`
+	Operation       string    `json:"operation"`
+	Project         string    `json:"project"`
+	User            string    `json:"user,omitempty"`
+	Hostname        string    `json:"hostname,omitempty"`
+	Ref             string    `json:"ref,omitempty"`
+	Commit          string    `json:"commit,omitempty"`
+	Release         string    `json:"release,omitempty"`
+	PreviousRelease string    `json:"previous_release,omitempty"`
+	Strategy        string    `json:"strategy,omitempty"`
+	Backup          string    `json:"backup,omitempty"`
+	StartedAt       time.Time `json:"started_at"`
+	DurationMS      int64     `json:"duration_ms"`
+	Outcome         string    `json:"outcome"`
+	Message         string    `json:"message,omitempty"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// Duration returns how long the operation took.
+func (e HistoryEntry) Duration() time.Duration {
+	return time.Duration(e.DurationMS) * time.Millisecond
+}
+
+// HistoryFilter selects history entries; zero fields match everything.
+type HistoryFilter struct {
+	Operation string
+	Outcome   string
+	User      string
+	Since     time.Time
+	// Limit keeps only the most recent entries when positive.
+	Limit int
+}
+
+// Match reports whether entry passes the filter, ignoring Limit.
+func (f HistoryFilter) Match(entry HistoryEntry) bool {
+	switch {
+	case f.Operation != "" && entry.Operation != f.Operation:
+		return false
+	case f.Outcome != "" && entry.Outcome != f.Outcome:
+		return false
+	case f.User != "" && entry.User != f.User:
+		return false
+	case !f.Since.IsZero() && entry.StartedAt.Before(f.Since):
+		return false
+	}
+	return true
+}
+
+// NewDeploymentID returns a unique, time-sortable identifier for an operation
+// started at t.
+func NewDeploymentID(t time.Time) string {
+	suffix := make([]byte, 3)
+	_, _ = rand.Read(suffix)
+	return NewReleaseID(t) + "-" + hex.EncodeToString(suffix)
+}
@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryFilterMatch(t *testing.T) {
+	started := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	entry := HistoryEntry{Operation: OperationPush, Outcome: OutcomeRolledBack, User: "alice", StartedAt: started}
+	tests := []struct {
+		name   string
+		filter HistoryFilter
+		want   bool
+	}{
+		{"empty filter", HistoryFilter{}, true},
+		{"limit is ignored", HistoryFilter{Limit: 1}, true},
+		{"operation", HistoryFilter{Operation: OperationPush}, true},
+		{"other operation", HistoryFilter{Operation: OperationRollback}, false},
+		{"outcome", HistoryFilter{Outcome: OutcomeRolledBack}, true},
+		{"other outcome", HistoryFilter{Outcome: OutcomeSuccess}, false},
+		{"user", HistoryFilter{User: "alice"}, true},
+		{"other user", HistoryFilter{User: "bob"}, false},
+		{"since before", HistoryFilter{Since: started.Add(-time.Hour)}, true},
+		{"since exactly", HistoryFilter{Since: started}, true},
+		{"since after", HistoryFilter{Since: started.Add(time.Second)}, false},
+		{"all fields", HistoryFilter{Operation: OperationPush, Outcome: OutcomeRolledBack, User: "alice", Since: started}, true},
+		{"one field off", HistoryFilter{Operation: OperationPush, Outcome: OutcomeRolledBack, User: "bob"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(entry); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
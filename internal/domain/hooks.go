@@ -0,0 +1,34 @@
+package domain
+
+// Lifecycle stages at which user-declared hooks run.
+const (
+	HookPreDeploy    = "pre-deploy"
+	HookPostDeploy   = "post-deploy"
+	HookPreRollback  = "pre-rollback"
+	HookPostRollback = "post-rollback"
+	HookOnFailure    = "on-failure"
+)
+
+// HookStages lists every supported stage in execution order.
+var HookStages = []string{HookPreDeploy, HookPostDeploy, HookPreRollback, HookPostRollback, HookOnFailure}
+
+// Hook is a user-declared command run at a lifecycle stage.
+type Hook struct {
+	Name    string
+	Command string
+	// Local runs the command on the machine invoking the CLI instead of the remote host.
+	Local bool
+	// Warn downgrades a failing hook to a warning instead of aborting the operation.
+	Warn bool
+}
+
+// HookResult captures the outcome of a single hook execution.
+type HookResult struct {
+	Stage   string
+	Name    string
+	Command string
+	Local   bool
+	Output  string
+	Success bool
+	Error   string
+}
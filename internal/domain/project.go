@@ -3,6 +3,7 @@ package domain
 import (
 	"fmt"
 	"path/filepath"
+	"time"
 )
 
 const (
@@ -11,26 +12,141 @@ const (
 	backupBasePath = "/var/backups"
 	lockBasePath   = "/var/locks"
 	logBasePath    = "/var/log/deploy"
+
+	defaultBranch = "main"
+
+	// releaseIDLayout formats release directory names so that they sort chronologically.
+	releaseIDLayout = "20060102150405"
 )
 
+// BasePaths are the remote roots under which per-project paths are created.
+type BasePaths struct {
+	Repo   string
+	Deploy string
+	Backup string
+	Lock   string
+	Log    string
+}
+
+// DefaultBasePaths returns the opinionated remote roots.
+func DefaultBasePaths() BasePaths {
+	return BasePaths{
+		Repo:   repoBasePath,
+		Deploy: deployBasePath,
+		Backup: backupBasePath,
+		Lock:   lockBasePath,
+		Log:    logBasePath,
+	}
+}
+
 // Project models a deployable project and the required remote paths.
+//
+// Application code lives under BaseDir using a releases layout:
+//
+//	BaseDir/releases/<id>/   one directory per deployment
+//	BaseDir/shared/          files linked into every release (.env, storage, ...)
+//	BaseDir/repo/            git checkout used to export new releases
+//	BaseDir/current          symlink to the active release
+//
+// DeployDir is the directory strategies operate in. It points at the current
+// symlink unless the project has been scoped to a release with WithRelease.
 type Project struct {
-	Name      string
-	RepoPath  string
-	DeployDir string
-	BackupDir string
-	LockFile  string
-	LogFile   string
+	Name        string
+	RepoPath    string
+	BaseDir     string
+	ReleasesDir string
+	SharedDir   string
+	SourceDir   string
+	CurrentLink string
+	DeployDir   string
+	Release     string
+	BackupDir   string
+	LockFile    string
+	LogFile     string
+	// HistoryFile is the JSON-lines ledger of every push and rollback.
+	HistoryFile string
+
+	// Branch is the git branch deployed by push.
+	Branch string
+	// Ref selects a tag or other ref to deploy instead of the tip of Branch.
+	Ref string
+	// Commit pins the deployment to an exact commit SHA.
+	Commit string
+	// Strategy forces a deployment strategy by name instead of auto-detection.
+	Strategy string
+	// Env is exported to every strategy and hook command.
+	Env map[string]string
+	// HealthChecks run against the activated release; a failure triggers a rollback.
+	HealthChecks []HealthCheck
+	// Hooks maps lifecycle stages to the commands run at that stage.
+	Hooks map[string][]Hook
+	// Artifact enables artifact mode when set.
+	Artifact *ArtifactBuild
+	// Backups configures the archive taken of the live release before each
+	// push and how many are kept.
+	Backups BackupPolicy
 }
 
 // NewProject builds a project with opinionated remote paths.
 func NewProject(name string) Project {
+	return NewProjectWithPaths(name, DefaultBasePaths())
+}
+
+// NewProjectWithPaths builds a project rooted at the given base paths.
+// Empty base paths fall back to the defaults.
+func NewProjectWithPaths(name string, paths BasePaths) Project {
+	defaults := DefaultBasePaths()
+	base := filepath.Join(orDefault(paths.Deploy, defaults.Deploy), name)
 	return Project{
-		Name:      name,
-		RepoPath:  filepath.Join(repoBasePath, fmt.Sprintf("%s.git", name)),
-		DeployDir: filepath.Join(deployBasePath, name),
-		BackupDir: filepath.Join(backupBasePath, name),
-		LockFile:  filepath.Join(lockBasePath, fmt.Sprintf("%s.lock", name)),
-		LogFile:   filepath.Join(logBasePath, fmt.Sprintf("%s.log", name)),
+		Name:        name,
+		RepoPath:    filepath.Join(orDefault(paths.Repo, defaults.Repo), fmt.Sprintf("%s.git", name)),
+		BaseDir:     base,
+		ReleasesDir: filepath.Join(base, "releases"),
+		SharedDir:   filepath.Join(base, "shared"),
+		SourceDir:   filepath.Join(base, "repo"),
+		CurrentLink: filepath.Join(base, "current"),
+		DeployDir:   filepath.Join(base, "current"),
+		BackupDir:   filepath.Join(orDefault(paths.Backup, defaults.Backup), name),
+		LockFile:    filepath.Join(orDefault(paths.Lock, defaults.Lock), fmt.Sprintf("%s.lock", name)),
+		LogFile:     filepath.Join(orDefault(paths.Log, defaults.Log), fmt.Sprintf("%s.log", name)),
+		HistoryFile: filepath.Join(base, "history.jsonl"),
+		Branch:      defaultBranch,
+		Backups:     DefaultBackupPolicy,
+	}
+}
+
+// Revision describes what push deploys: a commit, a ref or the tip of a branch.
+func (p Project) Revision() string {
+	switch {
+	case p.Commit != "":
+		return p.Commit
+	case p.Ref != "":
+		return p.Ref
+	default:
+		return p.Branch
+	}
+}
+
+// NewReleaseID returns a sortable release identifier for the given time.
+func NewReleaseID(t time.Time) string {
+	return t.UTC().Format(releaseIDLayout)
+}
+
+// ReleaseDir returns the directory of the given release.
+func (p Project) ReleaseDir(release string) string {
+	return filepath.Join(p.ReleasesDir, release)
+}
+
+// WithRelease returns a copy of the project whose DeployDir is the given release.
+func (p Project) WithRelease(release string) Project {
+	p.Release = release
+	p.DeployDir = p.ReleaseDir(release)
+	return p
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
 	}
+	return value
 }
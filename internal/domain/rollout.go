@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// RolloutPolicy controls how a deployment progresses across a host group.
+type RolloutPolicy struct {
+	// BatchSize is the number of hosts deployed concurrently. Zero deploys every host at once.
+	BatchSize int
+	// BatchPercent sizes batches as a percentage of the hosts when BatchSize is zero.
+	BatchPercent int
+	// Pause is the delay between two batches.
+	Pause time.Duration
+	// MaxFailures is the number of failed hosts tolerated before the rollout halts.
+	MaxFailures int
+	// RollbackOnHalt restores the previous release on hosts already updated when the rollout halts.
+	RollbackOnHalt bool
+}
+
+// Batches splits hosts into consecutive batches according to the policy.
+func (p RolloutPolicy) Batches(hosts []string) [][]string {
+	size := p.BatchSize
+	if size <= 0 && p.BatchPercent > 0 {
+		size = (len(hosts)*p.BatchPercent + 99) / 100
+	}
+	if size <= 0 || size > len(hosts) {
+		size = len(hosts)
+	}
+
+	var batches [][]string
+	for start := 0; start < len(hosts); start += size {
+		end := min(start+size, len(hosts))
+		batches = append(batches, hosts[start:end])
+	}
+	return batches
+}
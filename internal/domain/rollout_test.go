@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRolloutPolicyBatches(t *testing.T) {
+	hosts := []string{"web1", "web2", "web3", "web4", "web5"}
+	tests := []struct {
+		name   string
+		policy RolloutPolicy
+		hosts  []string
+		want   [][]string
+	}{
+		{"all at once", RolloutPolicy{}, hosts, [][]string{hosts}},
+		{"one by one", RolloutPolicy{BatchSize: 1}, hosts, [][]string{{"web1"}, {"web2"}, {"web3"}, {"web4"}, {"web5"}}},
+		{"uneven size", RolloutPolicy{BatchSize: 2}, hosts, [][]string{{"web1", "web2"}, {"web3", "web4"}, {"web5"}}},
+		{"size above host count", RolloutPolicy{BatchSize: 10}, hosts, [][]string{hosts}},
+		{"percent rounds up", RolloutPolicy{BatchPercent: 30}, hosts, [][]string{{"web1", "web2"}, {"web3", "web4"}, {"web5"}}},
+		{"small percent keeps one host", RolloutPolicy{BatchPercent: 1}, hosts, [][]string{{"web1"}, {"web2"}, {"web3"}, {"web4"}, {"web5"}}},
+		{"size wins over percent", RolloutPolicy{BatchSize: 4, BatchPercent: 20}, hosts, [][]string{{"web1", "web2", "web3", "web4"}, {"web5"}}},
+		{"no hosts", RolloutPolicy{BatchSize: 2}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Batches(tt.hosts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Batches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
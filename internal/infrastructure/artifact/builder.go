@@ -0,0 +1,94 @@
+package artifact
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dadyutenga/git-engine/internal/application"
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"github.com/dadyutenga/git-engine/internal/shared/shell"
+)
+
+// Builder implements application.ArtifactBuilder on the machine running the
+// CLI. Each build starts from a clean export of the revision, so uncommitted
+// changes in the working repository are never shipped.
+type Builder struct {
+	// Local runs git, the build command and tar in the working repository.
+	Local application.RemoteExecutor
+}
+
+// Build exports the project revision into a temporary directory, runs the
+// build command there (in a container when an image is configured) and
+// packages the output directory as a gzipped tarball.
+func (b Builder) Build(ctx context.Context, project domain.Project) (domain.Artifact, error) {
+	spec := project.Artifact
+	if spec == nil {
+		return domain.Artifact{}, fmt.Errorf("%s is not configured for artifact builds", project.Name)
+	}
+
+	out, err := b.Local.Run(ctx, fmt.Sprintf("git rev-parse --verify --quiet %s", shell.Escape(project.Revision()+"^{commit}")))
+	if err != nil {
+		return domain.Artifact{}, fmt.Errorf("%w: %s is not a local commit", domain.ErrRefNotReachable, project.Revision())
+	}
+	commit := strings.TrimSpace(out.Stdout)
+
+	workdir, err := os.MkdirTemp("", "deploy-"+project.Name+"-")
+	if err != nil {
+		return domain.Artifact{}, err
+	}
+	artifact := domain.Artifact{Path: filepath.Join(workdir, project.Name+".tgz"), Commit: commit}
+	src := filepath.Join(workdir, "src")
+
+	steps := []string{
+		fmt.Sprintf("mkdir -p %s && git archive --format=tar %s | tar -xf - -C %s", shell.Escape(src), shell.Escape(commit), shell.Escape(src)),
+		b.buildCommand(spec, src),
+		fmt.Sprintf("tar -czf %s -C %s .", shell.Escape(artifact.Path), shell.Escape(filepath.Join(src, spec.Output))),
+	}
+	for _, step := range steps {
+		if _, err := b.Local.Run(ctx, step); err != nil {
+			_ = b.Remove(artifact)
+			return domain.Artifact{}, err
+		}
+	}
+
+	artifact.SHA256, artifact.Size, err = checksum(artifact.Path)
+	if err != nil {
+		_ = b.Remove(artifact)
+		return domain.Artifact{}, err
+	}
+	return artifact, nil
+}
+
+// Remove deletes the build directory of the artifact.
+func (b Builder) Remove(artifact domain.Artifact) error {
+	return os.RemoveAll(filepath.Dir(artifact.Path))
+}
+
+func (b Builder) buildCommand(spec *domain.ArtifactBuild, src string) string {
+	if spec.Image != "" {
+		return fmt.Sprintf("docker run --rm -v %s -w /src %s sh -c %s", shell.Escape(src+":/src"), shell.Escape(spec.Image), shell.Escape(spec.Command))
+	}
+	return fmt.Sprintf("cd %s && %s", shell.Escape(src), spec.Command)
+}
+
+func checksum(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+var _ application.ArtifactBuilder = Builder{}
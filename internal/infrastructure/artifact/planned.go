@@ -0,0 +1,38 @@
+package artifact
+
+import (
+	"context"
+	"strings"
+
+	"github.com/dadyutenga/git-engine/internal/application"
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"github.com/dadyutenga/git-engine/internal/infrastructure/remote"
+)
+
+// Planned implements application.ArtifactBuilder for dry runs by recording
+// the build instead of running it.
+type Planned struct {
+	Plan *remote.Plan
+}
+
+// Build records the local build and returns a placeholder artifact.
+func (p Planned) Build(ctx context.Context, project domain.Project) (domain.Artifact, error) {
+	spec := project.Artifact
+	output := spec.Output
+	if output == "" {
+		output = "."
+	}
+	where := "locally"
+	if spec.Image != "" {
+		where = "in " + spec.Image
+	}
+	p.Plan.Record("local: build %s %s with %q and package %s", project.Revision(), where, spec.Command, output)
+	return domain.Artifact{Path: project.Name + ".tgz", SHA256: strings.Repeat("0", 64), Commit: project.Revision()}, nil
+}
+
+// Remove does nothing.
+func (p Planned) Remove(artifact domain.Artifact) error {
+	return nil
+}
+
+var _ application.ArtifactBuilder = Planned{}
@@ -0,0 +1,109 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/dadyutenga/git-engine/internal/application"
+	"github.com/dadyutenga/git-engine/internal/domain"
+)
+
+// Archives implements application.LocalArchives on the machine running the
+// CLI. Manifests are stored next to the archives as on the server.
+type Archives struct{}
+
+// Fetch downloads the archive into dir through a temporary file, which only
+// takes the archive's name once it matches the manifest.
+func (Archives) Fetch(fsys application.RemoteFileSystem, path string, backup domain.Backup, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(dir, backup.Name)
+	part := dst + ".part"
+	if err := fsys.Download(path, part); err != nil {
+		os.Remove(part)
+		return "", fmt.Errorf("download %s: %w", path, err)
+	}
+	sum, size, err := checksum(part)
+	if err == nil && backup.SHA256 != "" && (sum != backup.SHA256 || size != backup.Size) {
+		err = fmt.Errorf("%w: downloaded %s does not match its manifest", domain.ErrChecksumMismatch, backup.Name)
+	}
+	if err != nil {
+		os.Remove(part)
+		return "", err
+	}
+	if err := os.Rename(part, dst); err != nil {
+		os.Remove(part)
+		return "", err
+	}
+	backup.SHA256, backup.Size = sum, size
+	return dst, writeLocalManifest(dst, backup)
+}
+
+// Open reads the manifest next to the archive and checks the archive's size
+// and checksum against it.
+func (Archives) Open(path string) (domain.Backup, error) {
+	name := filepath.Base(path)
+	compression, ok := domain.BackupCompression(name)
+	if !ok {
+		return domain.Backup{}, fmt.Errorf("%s is not a backup archive (.tgz, .tar.zst or .tar)", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return domain.Backup{}, err
+	}
+	sum, size, err := checksum(path)
+	if err != nil {
+		return domain.Backup{}, err
+	}
+
+	data, err := os.ReadFile(path + ".json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return domain.Backup{Name: name, Store: domain.BackupStoreTarball, Compression: compression, SHA256: sum, Size: size, CreatedAt: info.ModTime().UTC()}, nil
+	}
+	if err != nil {
+		return domain.Backup{}, err
+	}
+	var b domain.Backup
+	if err := json.Unmarshal(data, &b); err != nil {
+		return domain.Backup{}, fmt.Errorf("invalid manifest for backup %s: %w", name, err)
+	}
+	if b.SHA256 != sum || b.Size != size {
+		return domain.Backup{}, fmt.Errorf("%w: %s does not match its manifest", domain.ErrChecksumMismatch, path)
+	}
+	b.Name, b.Compression, b.HasManifest = name, compression, true
+	return b, nil
+}
+
+// writeLocalManifest stores the manifest of the local archive path.
+func writeLocalManifest(path string, b domain.Backup) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+".json", append(data, '\n'), 0o644)
+}
+
+// checksum returns the SHA-256 digest and size of a local file.
+func checksum(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+var _ application.LocalArchives = Archives{}
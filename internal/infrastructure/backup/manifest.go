@@ -0,0 +1,115 @@
+// Package backup implements the backup stores: compressed tarballs and
+// hardlinked snapshot trees. Every backup has a JSON manifest next to it in
+// the project's backup directory.
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dadyutenga/git-engine/internal/application"
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"github.com/dadyutenga/git-engine/internal/shared/shell"
+)
+
+// manifestPath is where the manifest of the backup name is stored.
+func manifestPath(project domain.Project, name string) string {
+	return filepath.Join(project.BackupDir, name+".json")
+}
+
+// readManifest returns the manifest of the backup name; HasManifest is false
+// when there is none.
+func readManifest(fsys application.RemoteFileSystem, project domain.Project, name string) (domain.Backup, error) {
+	data, err := fsys.ReadFile(manifestPath(project, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return domain.Backup{Name: name}, nil
+	}
+	if err != nil {
+		return domain.Backup{}, err
+	}
+	var b domain.Backup
+	if err := json.Unmarshal(data, &b); err != nil {
+		return domain.Backup{}, fmt.Errorf("invalid manifest for backup %s: %w", name, err)
+	}
+	b.Name, b.HasManifest = name, true
+	return b, nil
+}
+
+// writeManifest stores the manifest of b.
+func writeManifest(fsys application.RemoteFileSystem, project domain.Project, b domain.Backup) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fsys.WriteFile(manifestPath(project, b.Name), append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write manifest of %s: %w", b.Name, err)
+	}
+	return nil
+}
+
+// removeManifest deletes the manifest of the backup name, if any.
+func removeManifest(fsys application.RemoteFileSystem, project domain.Project, name string) error {
+	if err := fsys.Remove(manifestPath(project, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove manifest of backup %s: %w", name, err)
+	}
+	return nil
+}
+
+// listNames returns the entries of the backup directory.
+func listNames(fsys application.RemoteFileSystem, project domain.Project) ([]string, error) {
+	exists, err := fsys.Exists(project.BackupDir)
+	if err != nil || !exists {
+		return nil, err
+	}
+	return fsys.List(project.BackupDir)
+}
+
+// parseDigest reads the output of a command printing a SHA-256 digest and a
+// byte count on separate lines. A dry run prints nothing and yields zeros.
+func parseDigest(stdout string) (string, int64, error) {
+	fields := strings.Fields(stdout)
+	if len(fields) == 0 {
+		return "", 0, nil
+	}
+	if len(fields) != 2 {
+		return "", 0, fmt.Errorf("unexpected checksum output %q", stdout)
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("unexpected size in %q", stdout)
+	}
+	return fields[0], size, nil
+}
+
+// backupName names a backup of project taken at b.CreatedAt.
+func backupName(project domain.Project, b domain.Backup, ext string) string {
+	return fmt.Sprintf("%s-%d%s", project.Name, b.CreatedAt.Unix(), ext)
+}
+
+// importUpload runs then once the upload at path matches sum, and removes the
+// upload afterwards either way.
+func importUpload(ctx context.Context, exec application.RemoteExecutor, sum, path, then string) (domain.CommandResult, error) {
+	out, err := exec.Run(ctx, shell.VerifiedUpload(sum, path, then))
+	if domain.ExitStatus(err) == shell.ChecksumMismatchStatus {
+		return out, fmt.Errorf("%w: uploaded %s", domain.ErrChecksumMismatch, filepath.Base(path))
+	}
+	return out, err
+}
+
+// ensureAbsent fails when the backup directory already holds name.
+func ensureAbsent(fsys application.RemoteFileSystem, project domain.Project, name string) error {
+	exists, err := fsys.Exists(filepath.Join(project.BackupDir, name))
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("backup %s already exists", name)
+	}
+	return nil
+}
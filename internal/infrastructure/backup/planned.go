@@ -0,0 +1,25 @@
+package backup
+
+import (
+	"path/filepath"
+
+	"github.com/dadyutenga/git-engine/internal/application"
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"github.com/dadyutenga/git-engine/internal/infrastructure/remote"
+)
+
+// PlannedArchives implements application.LocalArchives for dry runs by
+// recording downloads instead of running them. Local archives are still read.
+type PlannedArchives struct {
+	Archives
+	Plan *remote.Plan
+}
+
+// Fetch records the download.
+func (p PlannedArchives) Fetch(fsys application.RemoteFileSystem, path string, backup domain.Backup, dir string) (string, error) {
+	dst := filepath.Join(dir, backup.Name)
+	p.Plan.Record("local: download %s to %s", path, dst)
+	return dst, nil
+}
+
+var _ application.LocalArchives = PlannedArchives{}
@@ -0,0 +1,183 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dadyutenga/git-engine/internal/application"
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"github.com/dadyutenga/git-engine/internal/shared/shell"
+)
+
+// snapshotSuffix marks snapshot directories in the backup directory.
+const snapshotSuffix = ".snapshot"
+
+// SnapshotStore keeps every backup as a directory tree copied with rsync.
+// Files unchanged since the previous snapshot are hardlinked to it, so a
+// snapshot only takes the space of what changed.
+type SnapshotStore struct {
+	FS application.RemoteFileSystem
+}
+
+// Name returns the store identifier.
+func (SnapshotStore) Name() string { return domain.BackupStoreSnapshot }
+
+// Create copies the release next to the newest snapshot, hardlinking the
+// files both share. The checksum covers the path and content of every file.
+// The size is the space the snapshot added: du counts a hardlinked file only
+// at the first path it meets, so the newest snapshot is measured before it.
+func (s SnapshotStore) Create(ctx context.Context, project domain.Project, exec application.RemoteExecutor, backup domain.Backup) (domain.Backup, error) {
+	backup.Store, backup.Compression = s.Name(), ""
+	backup.Name = backupName(project, backup, snapshotSuffix)
+	dir := filepath.Join(project.BackupDir, backup.Name)
+
+	previous, err := s.List(ctx, project)
+	if err != nil {
+		return backup, err
+	}
+	linkDest, measured := "", shell.Escape(dir)
+	if len(previous) > 0 {
+		sort.Slice(previous, func(i, j int) bool { return previous[i].CreatedAt.After(previous[j].CreatedAt) })
+		newest := shell.Escape(filepath.Join(project.BackupDir, previous[0].Name))
+		linkDest, measured = " --link-dest="+newest, newest+" "+measured
+	}
+
+	out, err := exec.Run(ctx, fmt.Sprintf("mkdir -p %s && rsync -a --one-file-system%s%s %s/ %s/ && %s && du -sb %s | tail -n 1 | cut -f1",
+		shell.Escape(dir), linkDest, excludeFlags(backup.Excludes), shell.Escape(project.ReleaseDir(backup.Release)), shell.Escape(dir),
+		digestCommand(dir), measured))
+	if err != nil {
+		if _, cleanupErr := exec.Run(context.WithoutCancel(ctx), "rm -rf "+shell.Escape(dir)); cleanupErr != nil {
+			err = fmt.Errorf("%w (cleanup failed: %v)", err, cleanupErr)
+		}
+		return backup, err
+	}
+	if backup.SHA256, backup.Size, err = parseDigest(out.Stdout); err != nil {
+		return backup, fmt.Errorf("%s: %w", dir, err)
+	}
+	if err := writeManifest(s.FS, project, backup); err != nil {
+		return backup, err
+	}
+	backup.HasManifest = true
+	return backup, nil
+}
+
+// List returns the snapshot directories of the backup directory.
+func (s SnapshotStore) List(ctx context.Context, project domain.Project) ([]domain.Backup, error) {
+	names, err := listNames(s.FS, project)
+	if err != nil {
+		return nil, err
+	}
+	var backups []domain.Backup
+	for _, name := range names {
+		if !strings.HasSuffix(name, snapshotSuffix) {
+			continue
+		}
+		info, err := s.FS.Stat(filepath.Join(project.BackupDir, name))
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			continue
+		}
+		b, err := readManifest(s.FS, project, name)
+		if err != nil {
+			return nil, err
+		}
+		if !b.HasManifest {
+			b.CreatedAt = info.ModTime()
+		}
+		b.Store = s.Name()
+		backups = append(backups, b)
+	}
+	return backups, nil
+}
+
+// Verify recomputes the checksum of the tree. A dry run cannot compute it and
+// accepts the snapshot.
+func (s SnapshotStore) Verify(ctx context.Context, project domain.Project, exec application.RemoteExecutor, backup domain.Backup) error {
+	out, err := exec.Run(application.Retryable(ctx), digestCommand(filepath.Join(project.BackupDir, backup.Name)))
+	if err != nil {
+		return err
+	}
+	if sum := strings.TrimSpace(out.Stdout); sum != "" && sum != backup.SHA256 {
+		return fmt.Errorf("%w: %s does not match its manifest", domain.ErrChecksumMismatch, backup.Name)
+	}
+	return nil
+}
+
+// Restore copies the snapshot into dir. Files are copied rather than
+// hardlinked so the restored release cannot alter the snapshot.
+func (s SnapshotStore) Restore(ctx context.Context, project domain.Project, exec application.RemoteExecutor, backup domain.Backup, dir string) error {
+	_, err := exec.Run(ctx, fmt.Sprintf("cp -a %s/. %s/", shell.Escape(filepath.Join(project.BackupDir, backup.Name)), shell.Escape(dir)))
+	return err
+}
+
+// Remove deletes the snapshot and its manifest. Files still linked from
+// other snapshots stay with them.
+func (s SnapshotStore) Remove(ctx context.Context, project domain.Project, exec application.RemoteExecutor, backup domain.Backup) error {
+	if _, err := exec.Run(ctx, "rm -rf "+shell.Escape(filepath.Join(project.BackupDir, backup.Name))); err != nil {
+		return fmt.Errorf("remove backup %s: %w", backup.Name, err)
+	}
+	return removeManifest(s.FS, project, backup.Name)
+}
+
+// Export packs the snapshot into an uncompressed tar archive in the project's
+// base directory.
+func (s SnapshotStore) Export(ctx context.Context, project domain.Project, exec application.RemoteExecutor, backup domain.Backup) (string, domain.Backup, error) {
+	base := strings.TrimSuffix(backup.Name, snapshotSuffix)
+	archive := filepath.Join(project.BaseDir, ".export-"+base+".tar")
+	out, err := exec.Run(ctx, fmt.Sprintf("tar -cf %s -C %s . && sha256sum %s | cut -d' ' -f1 && wc -c < %s",
+		shell.Escape(archive), shell.Escape(filepath.Join(project.BackupDir, backup.Name)), shell.Escape(archive), shell.Escape(archive)))
+	if err != nil {
+		if _, cleanupErr := exec.Run(context.WithoutCancel(ctx), "rm -f "+shell.Escape(archive)); cleanupErr != nil {
+			err = fmt.Errorf("%w (cleanup failed: %v)", err, cleanupErr)
+		}
+		return "", backup, err
+	}
+	backup.Name = base + domain.BackupExtension(domain.CompressionNone)
+	backup.Store, backup.Compression = domain.BackupStoreTarball, domain.CompressionNone
+	if backup.SHA256, backup.Size, err = parseDigest(out.Stdout); err != nil {
+		return archive, backup, fmt.Errorf("%s: %w", archive, err)
+	}
+	return archive, backup, nil
+}
+
+// Import extracts the uploaded archive into a new snapshot once it matches
+// its checksum. The snapshot shares no files with older ones, so its size is
+// the size of the whole tree.
+func (s SnapshotStore) Import(ctx context.Context, project domain.Project, exec application.RemoteExecutor, backup domain.Backup, path string) (domain.Backup, error) {
+	compression, _ := domain.BackupCompression(backup.Name)
+	backup.Name = strings.TrimSuffix(backup.Name, domain.BackupExtension(compression)) + snapshotSuffix
+	backup.Store, backup.Compression = s.Name(), ""
+	if err := ensureAbsent(s.FS, project, backup.Name); err != nil {
+		return backup, err
+	}
+	dir := filepath.Join(project.BackupDir, backup.Name)
+	out, err := importUpload(ctx, exec, backup.SHA256, path, fmt.Sprintf("mkdir -p %s && tar -x%s -f %s -C %s && %s && du -sb %s | cut -f1",
+		shell.Escape(dir), tarCompression(compression), shell.Escape(path), shell.Escape(dir), digestCommand(dir), shell.Escape(dir)))
+	if err != nil {
+		if _, cleanupErr := exec.Run(context.WithoutCancel(ctx), "rm -rf "+shell.Escape(dir)); cleanupErr != nil {
+			err = fmt.Errorf("%w (cleanup failed: %v)", err, cleanupErr)
+		}
+		return backup, err
+	}
+	if backup.SHA256, backup.Size, err = parseDigest(out.Stdout); err != nil {
+		return backup, fmt.Errorf("%s: %w", dir, err)
+	}
+	if err := writeManifest(s.FS, project, backup); err != nil {
+		return backup, err
+	}
+	backup.HasManifest = true
+	return backup, nil
+}
+
+// digestCommand prints a SHA-256 digest of the path and content of every
+// file below dir, independent of the order find visits them in.
+func digestCommand(dir string) string {
+	return fmt.Sprintf("export LC_ALL=C && cd %s && find . -type f -exec sha256sum {} + | sort | sha256sum | cut -d' ' -f1", shell.Escape(dir))
+}
+
+var _ application.BackupStore = SnapshotStore{}
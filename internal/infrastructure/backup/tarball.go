@@ -0,0 +1,167 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dadyutenga/git-engine/internal/application"
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"github.com/dadyutenga/git-engine/internal/shared/shell"
+)
+
+// TarballStore keeps every backup as a single, optionally compressed, tar
+// archive.
+type TarballStore struct {
+	FS application.RemoteFileSystem
+}
+
+// Name returns the store identifier.
+func (TarballStore) Name() string { return domain.BackupStoreTarball }
+
+// Create archives the release with the compression recorded in backup.
+// Other file systems mounted inside the release are skipped.
+func (t TarballStore) Create(ctx context.Context, project domain.Project, exec application.RemoteExecutor, backup domain.Backup) (domain.Backup, error) {
+	if backup.Compression == "" {
+		backup.Compression = domain.CompressionGzip
+	}
+	backup.Store = t.Name()
+	backup.Name = backupName(project, backup, domain.BackupExtension(backup.Compression))
+	archive := filepath.Join(project.BackupDir, backup.Name)
+
+	out, err := exec.Run(ctx, fmt.Sprintf("tar -c%s --one-file-system%s -f %s -C %s . && sha256sum %s | cut -d' ' -f1 && wc -c < %s",
+		tarCompression(backup.Compression), excludeFlags(backup.Excludes), shell.Escape(archive),
+		shell.Escape(project.ReleaseDir(backup.Release)), shell.Escape(archive), shell.Escape(archive)))
+	if err != nil {
+		_ = t.FS.Remove(archive)
+		return backup, err
+	}
+	if backup.SHA256, backup.Size, err = parseDigest(out.Stdout); err != nil {
+		return backup, fmt.Errorf("%s: %w", archive, err)
+	}
+	if err := writeManifest(t.FS, project, backup); err != nil {
+		return backup, err
+	}
+	backup.HasManifest = true
+	return backup, nil
+}
+
+// List returns the archives of the backup directory, described by their
+// manifests when present.
+func (t TarballStore) List(ctx context.Context, project domain.Project) ([]domain.Backup, error) {
+	names, err := listNames(t.FS, project)
+	if err != nil {
+		return nil, err
+	}
+	var backups []domain.Backup
+	for _, name := range names {
+		if _, ok := domain.BackupCompression(name); !ok {
+			continue
+		}
+		info, err := t.FS.Stat(filepath.Join(project.BackupDir, name))
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			continue
+		}
+		b, err := readManifest(t.FS, project, name)
+		if err != nil {
+			return nil, err
+		}
+		if !b.HasManifest {
+			b.Size, b.CreatedAt = info.Size(), info.ModTime()
+		}
+		if b.Compression == "" {
+			b.Compression, _ = domain.BackupCompression(name)
+		}
+		b.Store = t.Name()
+		backups = append(backups, b)
+	}
+	return backups, nil
+}
+
+// Verify compares the size and checksum of the archive with its manifest.
+func (t TarballStore) Verify(ctx context.Context, project domain.Project, exec application.RemoteExecutor, backup domain.Backup) error {
+	archive := filepath.Join(project.BackupDir, backup.Name)
+	info, err := t.FS.Stat(archive)
+	if err != nil {
+		return err
+	}
+	if info.Size() != backup.Size {
+		return fmt.Errorf("%w: %s is %d bytes, its manifest records %d", domain.ErrChecksumMismatch, backup.Name, info.Size(), backup.Size)
+	}
+	if _, err := exec.Run(application.Retryable(ctx), fmt.Sprintf("echo %s | sha256sum -c --status", shell.Escape(backup.SHA256+"  "+archive))); err != nil {
+		if domain.ExitStatus(err) == 1 {
+			return fmt.Errorf("%w: %s does not match its manifest", domain.ErrChecksumMismatch, backup.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+// Restore extracts the archive into dir.
+func (t TarballStore) Restore(ctx context.Context, project domain.Project, exec application.RemoteExecutor, backup domain.Backup, dir string) error {
+	archive := filepath.Join(project.BackupDir, backup.Name)
+	_, err := exec.Run(ctx, fmt.Sprintf("tar -x%s -f %s -C %s", tarCompression(backup.Compression), shell.Escape(archive), shell.Escape(dir)))
+	return err
+}
+
+// Remove deletes the archive and its manifest.
+func (t TarballStore) Remove(ctx context.Context, project domain.Project, exec application.RemoteExecutor, backup domain.Backup) error {
+	if err := t.FS.Remove(filepath.Join(project.BackupDir, backup.Name)); err != nil {
+		return fmt.Errorf("remove backup %s: %w", backup.Name, err)
+	}
+	return removeManifest(t.FS, project, backup.Name)
+}
+
+// Export returns the archive itself.
+func (t TarballStore) Export(ctx context.Context, project domain.Project, exec application.RemoteExecutor, backup domain.Backup) (string, domain.Backup, error) {
+	return filepath.Join(project.BackupDir, backup.Name), backup, nil
+}
+
+// Import moves the uploaded archive into the backup directory once it
+// matches its checksum.
+func (t TarballStore) Import(ctx context.Context, project domain.Project, exec application.RemoteExecutor, backup domain.Backup, path string) (domain.Backup, error) {
+	backup.Store = t.Name()
+	backup.Compression, _ = domain.BackupCompression(backup.Name)
+	if err := ensureAbsent(t.FS, project, backup.Name); err != nil {
+		return backup, err
+	}
+	archive := filepath.Join(project.BackupDir, backup.Name)
+	if _, err := importUpload(ctx, exec, backup.SHA256, path, fmt.Sprintf("mv %s %s", shell.Escape(path), shell.Escape(archive))); err != nil {
+		return backup, err
+	}
+	if err := writeManifest(t.FS, project, backup); err != nil {
+		return backup, err
+	}
+	backup.HasManifest = true
+	return backup, nil
+}
+
+// tarCompression returns the tar flags selecting compression, with a
+// leading space.
+func tarCompression(compression string) string {
+	switch compression {
+	case domain.CompressionZstd:
+		return " -I zstd"
+	case domain.CompressionNone:
+		return ""
+	default:
+		return " -z"
+	}
+}
+
+// excludeFlags renders patterns as --exclude flags, with a leading space.
+// tar and rsync both match patterns without a slash against every path
+// component.
+func excludeFlags(patterns []string) string {
+	var b strings.Builder
+	for _, pattern := range patterns {
+		b.WriteString(" --exclude=" + shell.Escape(pattern))
+	}
+	return b.String()
+}
+
+var _ application.BackupStore = TarballStore{}
@@ -0,0 +1,158 @@
+// Package database dumps and restores the MySQL, PostgreSQL and SQLite
+// databases of deployed projects with the engines' own command line tools.
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dadyutenga/git-engine/internal/application"
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"github.com/dadyutenga/git-engine/internal/shared/shell"
+)
+
+// Dumper implements application.DatabaseDumper. Passwords are handed to the
+// clients through a temporary credentials file, never on the command line.
+type Dumper struct {
+	FS application.RemoteFileSystem
+}
+
+// Dump writes the dump uncompressed next to path, then gzips it to path.
+// SQLite databases are copied with the online backup API.
+func (d Dumper) Dump(ctx context.Context, exec application.RemoteExecutor, db domain.Database, path string) (domain.DatabaseDump, error) {
+	dump := domain.DatabaseDump{File: filepath.Base(path), Engine: db.Engine}
+	raw := strings.TrimSuffix(path, ".gz")
+	credentials, err := d.writeCredentials(ctx, exec, db, path)
+	defer d.removeCredentials(credentials)
+	if err != nil {
+		return dump, err
+	}
+
+	var cmd string
+	switch db.Engine {
+	case domain.DatabaseMySQL:
+		cmd = fmt.Sprintf("mysqldump --defaults-extra-file=%s --single-transaction --routines --triggers --no-tablespaces --result-file=%s %s",
+			shell.Escape(credentials), shell.Escape(raw), shell.Escape(db.Name))
+	case domain.DatabasePostgres:
+		cmd = fmt.Sprintf("PGPASSFILE=%s pg_dump%s --clean --if-exists --no-owner -f %s %s",
+			shell.Escape(credentials), postgresFlags(db), shell.Escape(raw), shell.Escape(db.Name))
+	case domain.DatabaseSQLite:
+		// The dot command quotes the path in SQL style.
+		cmd = fmt.Sprintf("sqlite3 %s %s", shell.Escape(db.Name), shell.Escape(".backup '"+strings.ReplaceAll(raw, "'", "''")+"'"))
+	default:
+		return dump, fmt.Errorf("unsupported database %q", db.Engine)
+	}
+
+	out, err := exec.Run(ctx, fmt.Sprintf("%s && gzip -f %s && sha256sum %s | cut -d' ' -f1 && wc -c < %s",
+		cmd, shell.Escape(raw), shell.Escape(path), shell.Escape(path)))
+	if err != nil {
+		if _, cleanupErr := exec.Run(context.WithoutCancel(ctx), fmt.Sprintf("rm -f %s %s", shell.Escape(raw), shell.Escape(path))); cleanupErr != nil {
+			err = fmt.Errorf("%w (cleanup failed: %v)", err, cleanupErr)
+		}
+		return dump, err
+	}
+	if fields := strings.Fields(out.Stdout); len(fields) == 2 {
+		dump.SHA256 = fields[0]
+		dump.Size, err = strconv.ParseInt(fields[1], 10, 64)
+	} else if len(fields) != 0 {
+		err = fmt.Errorf("unexpected checksum output %q", out.Stdout)
+	}
+	return dump, err
+}
+
+// Restore pipes the dump into the database client. A SQLite database file is
+// replaced in a single rename.
+func (d Dumper) Restore(ctx context.Context, exec application.RemoteExecutor, db domain.Database, path string) error {
+	credentials, err := d.writeCredentials(ctx, exec, db, path)
+	defer d.removeCredentials(credentials)
+	if err != nil {
+		return err
+	}
+
+	var cmd string
+	switch db.Engine {
+	case domain.DatabaseMySQL:
+		cmd = fmt.Sprintf("gunzip -c %s | mysql --defaults-extra-file=%s %s", shell.Escape(path), shell.Escape(credentials), shell.Escape(db.Name))
+	case domain.DatabasePostgres:
+		cmd = fmt.Sprintf("gunzip -c %s | PGPASSFILE=%s psql%s -q -v ON_ERROR_STOP=1 -d %s", shell.Escape(path), shell.Escape(credentials), postgresFlags(db), shell.Escape(db.Name))
+	case domain.DatabaseSQLite:
+		tmp := db.Name + ".restore"
+		cmd = fmt.Sprintf("gunzip -c %s > %s && mv %s %s", shell.Escape(path), shell.Escape(tmp), shell.Escape(tmp), shell.Escape(db.Name))
+	default:
+		return fmt.Errorf("unsupported database %q", db.Engine)
+	}
+	_, err = exec.Run(ctx, cmd)
+	return err
+}
+
+// writeCredentials stores the credentials of db in a hidden file next to the
+// dump at path and returns its path. mktemp gives every run its own file,
+// created with mode 0600 before anything is written to it. The path is
+// returned even when writing fails so the caller can remove the file. SQLite
+// needs none and gets "".
+func (d Dumper) writeCredentials(ctx context.Context, exec application.RemoteExecutor, db domain.Database, path string) (string, error) {
+	var content string
+	switch db.Engine {
+	case domain.DatabaseMySQL:
+		content = "[client]\n"
+		for _, option := range [][2]string{{"host", db.Host}, {"port", db.Port}, {"user", db.User}, {"password", db.Password}} {
+			if option[1] != "" {
+				content += fmt.Sprintf("%s=\"%s\"\n", option[0], optionEscaper.Replace(option[1]))
+			}
+		}
+	case domain.DatabasePostgres:
+		escape := strings.NewReplacer(`\`, `\\`, `:`, `\:`).Replace
+		content = fmt.Sprintf("*:*:*:%s:%s\n", escape(db.User), escape(db.Password))
+	default:
+		return "", nil
+	}
+	pattern := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".credentials.XXXXXX")
+	out, err := exec.Run(ctx, "mktemp "+shell.Escape(pattern))
+	if err != nil {
+		return "", fmt.Errorf("create database credentials file: %w", err)
+	}
+	file := strings.TrimSpace(out.Stdout)
+	if file == "" {
+		// Dry runs record mktemp without running it.
+		file = pattern
+	}
+	if err := d.FS.WriteFile(file, []byte(content), 0o600); err != nil {
+		return file, fmt.Errorf("write database credentials: %w", err)
+	}
+	return file, nil
+}
+
+// optionEscaper escapes a value for a double-quoted MySQL option file value.
+// Other bytes, including non-ASCII ones, are read verbatim.
+var optionEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`, "\b", `\b`, "\x00", `\0`)
+
+// removeCredentials deletes a file written by writeCredentials.
+func (d Dumper) removeCredentials(file string) {
+	if file == "" {
+		return
+	}
+	if err := d.FS.Remove(file); err != nil {
+		log.Printf("WARNING: failed to remove database credentials %s: %v", file, err)
+	}
+}
+
+// postgresFlags selects the server and user of db, with a leading space.
+func postgresFlags(db domain.Database) string {
+	var flags string
+	if db.Host != "" {
+		flags += " -h " + shell.Escape(db.Host)
+	}
+	if db.Port != "" {
+		flags += " -p " + shell.Escape(db.Port)
+	}
+	if db.User != "" {
+		flags += " -U " + shell.Escape(db.User)
+	}
+	return flags
+}
+
+var _ application.DatabaseDumper = Dumper{}
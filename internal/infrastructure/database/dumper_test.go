@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"io"
+	iofs "io/fs"
+	"strings"
+	"testing"
+
+	"github.com/dadyutenga/git-engine/internal/application"
+	"github.com/dadyutenga/git-engine/internal/domain"
+)
+
+// credentialsFS keeps the files written to it.
+type credentialsFS struct {
+	application.RemoteFileSystem
+	files map[string]string
+}
+
+func (f *credentialsFS) WriteFile(path string, data []byte, perm iofs.FileMode) error {
+	f.files[path] = string(data)
+	return nil
+}
+
+func (f *credentialsFS) Remove(path string) error { return nil }
+
+// recordingExecutor records commands and answers them without output.
+type recordingExecutor struct {
+	commands []string
+}
+
+func (e *recordingExecutor) Run(ctx context.Context, command string) (domain.CommandResult, error) {
+	e.commands = append(e.commands, command)
+	return domain.CommandResult{}, nil
+}
+
+func (e *recordingExecutor) RunStream(ctx context.Context, command string, writer io.Writer) error {
+	e.commands = append(e.commands, command)
+	return nil
+}
+
+func TestMySQLCredentialsEscaping(t *testing.T) {
+	fsys := &credentialsFS{files: map[string]string{}}
+	db := domain.Database{Engine: domain.DatabaseMySQL, Name: "shop", User: "app", Password: "p\"a\\ss\n\t\r\b\x00wörd"}
+	if _, err := (Dumper{FS: fsys}).Dump(context.Background(), &recordingExecutor{}, db, "/var/backups/shop/db.sql.gz"); err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	if len(fsys.files) != 1 {
+		t.Fatalf("wrote %d credentials files, want 1", len(fsys.files))
+	}
+	for _, content := range fsys.files {
+		want := "[client]\nuser=\"app\"\npassword=\"p\\\"a\\\\ss\\n\\t\\r\\b\\0wörd\"\n"
+		if content != want {
+			t.Errorf("credentials = %q, want %q", content, want)
+		}
+	}
+}
+
+func TestSQLiteBackupQuotesPath(t *testing.T) {
+	exec := &recordingExecutor{}
+	db := domain.Database{Engine: domain.DatabaseSQLite, Name: "/srv/shop/db.sqlite"}
+	if _, err := (Dumper{}).Dump(context.Background(), exec, db, "/var/backups/o'brien/db.sqlite.gz"); err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	want := `'.backup '\''/var/backups/o'\'''\''brien/db.sqlite'\'''`
+	if len(exec.commands) == 0 || !strings.Contains(exec.commands[0], want) {
+		t.Errorf("command = %q, want it to contain %s", exec.commands, want)
+	}
+}
@@ -1,6 +1,7 @@
 package detectors
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -11,44 +12,46 @@ import (
 
 // DockerStrategy deploys docker-compose based projects.
 type DockerStrategy struct {
-	Exec application.RemoteExecutor
-	FS   application.RemoteFileSystem
+	FS application.RemoteFileSystem
 }
 
 // Name returns the strategy identifier.
 func (d DockerStrategy) Name() string { return "docker" }
 
 // Detect checks for docker compose files using a single batched command.
-func (d DockerStrategy) Detect(fs application.RemoteFileSystem, project domain.Project) (bool, error) {
-	cmd := fmt.Sprintf("( [ -f %s ] || [ -f %s ] ) && echo found || echo missing",
+func (d DockerStrategy) Detect(ctx context.Context, fs application.RemoteFileSystem, project domain.Project, exec application.RemoteExecutor) (bool, error) {
+	cmd := fmt.Sprintf("[ -f %s ] || [ -f %s ]",
 		shell.Escape(project.DeployDir+"/docker-compose.yml"),
 		shell.Escape(project.DeployDir+"/compose.yml"))
-	out, err := d.Exec.Run(cmd)
-	if err != nil {
-		return false, err
-	}
-	return strings.Contains(out, "found"), nil
+	_, err := exec.Run(ctx, cmd)
+	return testResult(err)
 }
 
-// Deploy runs docker compose build+up.
-func (d DockerStrategy) Deploy(project domain.Project, exec application.RemoteExecutor) error {
-	_, err := exec.Run(fmt.Sprintf("cd %s && docker compose down && docker compose up -d --build", shell.Escape(project.DeployDir)))
+// Deploy builds the images of the release while the running stack stays up.
+func (d DockerStrategy) Deploy(ctx context.Context, project domain.Project, exec application.RemoteExecutor) error {
+	_, err := exec.Run(ctx, fmt.Sprintf("cd %s && docker compose -p %s build", shell.Escape(project.DeployDir), shell.Escape(project.Name)))
 	return err
 }
 
-// Restart restarts docker compose services.
-func (d DockerStrategy) Restart(project domain.Project, exec application.RemoteExecutor) error {
-	_, err := exec.Run(fmt.Sprintf("cd %s && docker compose restart", shell.Escape(project.DeployDir)))
+// Restart recreates docker compose services from the active release. The
+// compose project name is pinned so that every release replaces the same
+// stack; images are rebuilt because releases share their tags.
+func (d DockerStrategy) Restart(ctx context.Context, project domain.Project, exec application.RemoteExecutor) error {
+	_, err := exec.Run(ctx, fmt.Sprintf("cd %s && docker compose -p %s up -d --build", shell.Escape(project.DeployDir), shell.Escape(project.Name)))
 	return err
 }
 
 // Status reports running state via docker compose.
-func (d DockerStrategy) Status(project domain.Project, exec application.RemoteExecutor) (bool, error) {
-	out, err := exec.Run(fmt.Sprintf("cd %s && docker compose ps --status running", shell.Escape(project.DeployDir)))
+func (d DockerStrategy) Status(ctx context.Context, project domain.Project, exec application.RemoteExecutor) (bool, error) {
+	out, err := exec.Run(application.Retryable(ctx), fmt.Sprintf("cd %s && docker compose -p %s ps --status running", shell.Escape(project.DeployDir), shell.Escape(project.Name)))
 	if err != nil {
 		return false, err
 	}
-	return strings.Contains(out, "running"), nil
+	return strings.Contains(out.Stdout, "running"), nil
 }
 
+// BackupExcludes skips the conventional directory of bind-mounted volumes;
+// images are rebuilt by Deploy.
+func (DockerStrategy) BackupExcludes() []string { return []string{"volumes"} }
+
 var _ application.DeploymentStrategy = DockerStrategy{}
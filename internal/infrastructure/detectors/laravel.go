@@ -1,8 +1,8 @@
 package detectors
 
 import (
+	"context"
 	"fmt"
-	"strings"
 
 	"github.com/dadyutenga/git-engine/internal/application"
 	"github.com/dadyutenga/git-engine/internal/domain"
@@ -10,45 +10,43 @@ import (
 )
 
 // LaravelStrategy handles Laravel/PHP deployments.
-type LaravelStrategy struct {
-	Exec application.RemoteExecutor
-}
+type LaravelStrategy struct{}
 
 // Name returns strategy identifier.
 func (LaravelStrategy) Name() string { return "laravel" }
 
 // Detect checks for artisan and composer.json using a single batched command.
-func (l LaravelStrategy) Detect(fs application.RemoteFileSystem, project domain.Project) (bool, error) {
-	cmd := fmt.Sprintf("( [ -f %s ] && [ -f %s ] ) && echo found || echo missing",
+func (LaravelStrategy) Detect(ctx context.Context, fs application.RemoteFileSystem, project domain.Project, exec application.RemoteExecutor) (bool, error) {
+	cmd := fmt.Sprintf("[ -f %s ] && [ -f %s ]",
 		shell.Escape(project.DeployDir+"/artisan"),
 		shell.Escape(project.DeployDir+"/composer.json"))
-	out, err := l.Exec.Run(cmd)
-	if err != nil {
-		return false, err
-	}
-	return strings.Contains(out, "found"), nil
+	_, err := exec.Run(ctx, cmd)
+	return testResult(err)
 }
 
 // Deploy installs composer deps and optimizes.
-func (LaravelStrategy) Deploy(project domain.Project, exec application.RemoteExecutor) error {
+func (LaravelStrategy) Deploy(ctx context.Context, project domain.Project, exec application.RemoteExecutor) error {
 	cmd := fmt.Sprintf("cd %s && composer install --no-dev --optimize-autoloader && php artisan config:cache && php artisan migrate --force", shell.Escape(project.DeployDir))
-	_, err := exec.Run(cmd)
+	_, err := exec.Run(ctx, cmd)
 	return err
 }
 
 // Restart reloads php-fpm if available.
-func (LaravelStrategy) Restart(project domain.Project, exec application.RemoteExecutor) error {
-	_, err := exec.Run("systemctl restart php-fpm || true")
+func (LaravelStrategy) Restart(ctx context.Context, project domain.Project, exec application.RemoteExecutor) error {
+	_, err := exec.Run(ctx, "systemctl restart php-fpm || true")
 	return err
 }
 
 // Status checks php-fpm activity.
-func (LaravelStrategy) Status(project domain.Project, exec application.RemoteExecutor) (bool, error) {
-	out, err := exec.Run("systemctl is-active php-fpm || true")
-	if err != nil {
-		return false, err
+func (LaravelStrategy) Status(ctx context.Context, project domain.Project, exec application.RemoteExecutor) (bool, error) {
+	_, err := exec.Run(application.Retryable(ctx), "systemctl is-active --quiet php-fpm")
+	if domain.ExitStatus(err) > 0 {
+		return false, nil
 	}
-	return strings.Contains(out, "active"), nil
+	return err == nil, err
 }
 
+// BackupExcludes skips the dependencies composer and npm restore.
+func (LaravelStrategy) BackupExcludes() []string { return []string{"vendor", "node_modules"} }
+
 var _ application.DeploymentStrategy = LaravelStrategy{}
@@ -1,6 +1,7 @@
 package detectors
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -16,30 +17,35 @@ type NodeStrategy struct{}
 func (NodeStrategy) Name() string { return "node" }
 
 // Detect determines if package.json exists.
-func (NodeStrategy) Detect(fs application.RemoteFileSystem, project domain.Project) (bool, error) {
+func (NodeStrategy) Detect(ctx context.Context, fs application.RemoteFileSystem, project domain.Project, _ application.RemoteExecutor) (bool, error) {
 	return fs.Exists(project.DeployDir + "/package.json")
 }
 
-// Deploy installs dependencies and restarts pm2.
-func (NodeStrategy) Deploy(project domain.Project, exec application.RemoteExecutor) error {
-	cmd := fmt.Sprintf("cd %s && npm install --production && (pm2 restart %s || pm2 start npm --name %s -- start)", shell.Escape(project.DeployDir), shell.Escape(project.Name), shell.Escape(project.Name))
-	_, err := exec.Run(cmd)
+// Deploy installs dependencies; pm2 is restarted once the release is live.
+func (NodeStrategy) Deploy(ctx context.Context, project domain.Project, exec application.RemoteExecutor) error {
+	_, err := exec.Run(ctx, fmt.Sprintf("cd %s && npm install --production", shell.Escape(project.DeployDir)))
 	return err
 }
 
-// Restart restarts pm2 process.
-func (NodeStrategy) Restart(project domain.Project, exec application.RemoteExecutor) error {
-	_, err := exec.Run(fmt.Sprintf("pm2 restart %s", shell.Escape(project.Name)))
+// Restart re-registers the pm2 process so it runs from the project directory.
+// pm2 pins the working directory at start time, so a plain restart would keep
+// serving the previous release.
+func (NodeStrategy) Restart(ctx context.Context, project domain.Project, exec application.RemoteExecutor) error {
+	cmd := fmt.Sprintf("cd %s && (pm2 delete %s >/dev/null 2>&1 || true) && pm2 start npm --name %s -- start", shell.Escape(project.DeployDir), shell.Escape(project.Name), shell.Escape(project.Name))
+	_, err := exec.Run(ctx, cmd)
 	return err
 }
 
 // Status returns pm2 process state.
-func (NodeStrategy) Status(project domain.Project, exec application.RemoteExecutor) (bool, error) {
-	out, err := exec.Run(fmt.Sprintf("pm2 describe %s", shell.Escape(project.Name)))
+func (NodeStrategy) Status(ctx context.Context, project domain.Project, exec application.RemoteExecutor) (bool, error) {
+	out, err := exec.Run(application.Retryable(ctx), fmt.Sprintf("pm2 describe %s", shell.Escape(project.Name)))
 	if err != nil {
 		return false, err
 	}
-	return strings.Contains(out, "online"), nil
+	return strings.Contains(out.Stdout, "online"), nil
 }
 
+// BackupExcludes skips the dependencies npm install restores.
+func (NodeStrategy) BackupExcludes() []string { return []string{"node_modules"} }
+
 var _ application.DeploymentStrategy = NodeStrategy{}
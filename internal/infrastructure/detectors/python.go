@@ -1,8 +1,8 @@
 package detectors
 
 import (
+	"context"
 	"fmt"
-	"strings"
 
 	"github.com/dadyutenga/git-engine/internal/application"
 	"github.com/dadyutenga/git-engine/internal/domain"
@@ -10,45 +10,43 @@ import (
 )
 
 // PythonStrategy deploys Python services.
-type PythonStrategy struct {
-	Exec application.RemoteExecutor
-}
+type PythonStrategy struct{}
 
 // Name returns identifier.
 func (PythonStrategy) Name() string { return "python" }
 
 // Detect checks for requirements.txt or pyproject.toml using a single batched command.
-func (p PythonStrategy) Detect(fs application.RemoteFileSystem, project domain.Project) (bool, error) {
-	cmd := fmt.Sprintf("( [ -f %s ] || [ -f %s ] ) && echo found || echo missing",
+func (PythonStrategy) Detect(ctx context.Context, fs application.RemoteFileSystem, project domain.Project, exec application.RemoteExecutor) (bool, error) {
+	cmd := fmt.Sprintf("[ -f %s ] || [ -f %s ]",
 		shell.Escape(project.DeployDir+"/requirements.txt"),
 		shell.Escape(project.DeployDir+"/pyproject.toml"))
-	out, err := p.Exec.Run(cmd)
-	if err != nil {
-		return false, err
-	}
-	return strings.Contains(out, "found"), nil
+	_, err := exec.Run(ctx, cmd)
+	return testResult(err)
 }
 
 // Deploy installs dependencies.
-func (PythonStrategy) Deploy(project domain.Project, exec application.RemoteExecutor) error {
+func (PythonStrategy) Deploy(ctx context.Context, project domain.Project, exec application.RemoteExecutor) error {
 	cmd := fmt.Sprintf("cd %s && if [ -f requirements.txt ]; then pip install -r requirements.txt; fi", shell.Escape(project.DeployDir))
-	_, err := exec.Run(cmd)
+	_, err := exec.Run(ctx, cmd)
 	return err
 }
 
 // Restart attempts to restart a systemd service matching project name.
-func (PythonStrategy) Restart(project domain.Project, exec application.RemoteExecutor) error {
-	_, err := exec.Run(fmt.Sprintf("systemctl restart %s || true", shell.Escape(project.Name)))
+func (PythonStrategy) Restart(ctx context.Context, project domain.Project, exec application.RemoteExecutor) error {
+	_, err := exec.Run(ctx, fmt.Sprintf("systemctl restart %s || true", shell.Escape(project.Name)))
 	return err
 }
 
 // Status checks systemd service state.
-func (PythonStrategy) Status(project domain.Project, exec application.RemoteExecutor) (bool, error) {
-	out, err := exec.Run(fmt.Sprintf("systemctl is-active %s || true", shell.Escape(project.Name)))
-	if err != nil {
-		return false, err
+func (PythonStrategy) Status(ctx context.Context, project domain.Project, exec application.RemoteExecutor) (bool, error) {
+	_, err := exec.Run(application.Retryable(ctx), fmt.Sprintf("systemctl is-active --quiet %s", shell.Escape(project.Name)))
+	if domain.ExitStatus(err) > 0 {
+		return false, nil
 	}
-	return strings.Contains(out, "active"), nil
+	return err == nil, err
 }
 
+// BackupExcludes skips compiled bytecode.
+func (PythonStrategy) BackupExcludes() []string { return []string{"__pycache__", "*.pyc"} }
+
 var _ application.DeploymentStrategy = PythonStrategy{}
@@ -0,0 +1,16 @@
+package detectors
+
+import "github.com/dadyutenga/git-engine/internal/domain"
+
+// testResult interprets the outcome of a shell test command: exit status 0
+// is true and 1 is false; anything else is reported as an error.
+func testResult(err error) (bool, error) {
+	switch {
+	case err == nil:
+		return true, nil
+	case domain.ExitStatus(err) == 1:
+		return false, nil
+	default:
+		return false, err
+	}
+}
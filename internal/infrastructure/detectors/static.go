@@ -1,6 +1,8 @@
 package detectors
 
 import (
+	"context"
+
 	"github.com/dadyutenga/git-engine/internal/application"
 	"github.com/dadyutenga/git-engine/internal/domain"
 )
@@ -12,19 +14,26 @@ type StaticStrategy struct{}
 func (StaticStrategy) Name() string { return "static" }
 
 // Detect always returns true as a fallback.
-func (StaticStrategy) Detect(_ application.RemoteFileSystem, _ domain.Project) (bool, error) {
+func (StaticStrategy) Detect(_ context.Context, _ application.RemoteFileSystem, _ domain.Project, _ application.RemoteExecutor) (bool, error) {
 	return true, nil
 }
 
 // Deploy performs a no-op to keep interface parity.
-func (StaticStrategy) Deploy(_ domain.Project, _ application.RemoteExecutor) error { return nil }
+func (StaticStrategy) Deploy(_ context.Context, _ domain.Project, _ application.RemoteExecutor) error {
+	return nil
+}
 
 // Restart performs nothing for static assets.
-func (StaticStrategy) Restart(_ domain.Project, _ application.RemoteExecutor) error { return nil }
+func (StaticStrategy) Restart(_ context.Context, _ domain.Project, _ application.RemoteExecutor) error {
+	return nil
+}
 
 // Status always returns true for static files.
-func (StaticStrategy) Status(_ domain.Project, _ application.RemoteExecutor) (bool, error) {
+func (StaticStrategy) Status(_ context.Context, _ domain.Project, _ application.RemoteExecutor) (bool, error) {
 	return true, nil
 }
 
+// BackupExcludes skips nothing; static releases are archived whole.
+func (StaticStrategy) BackupExcludes() []string { return nil }
+
 var _ application.DeploymentStrategy = StaticStrategy{}
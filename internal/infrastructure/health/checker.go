@@ -0,0 +1,120 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dadyutenga/git-engine/internal/application"
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"github.com/dadyutenga/git-engine/internal/infrastructure/remote"
+	"github.com/dadyutenga/git-engine/internal/shared/shell"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Checker runs health checks on the remote host so that services bound to
+// loopback interfaces can be probed.
+type Checker struct{}
+
+// Check performs one attempt of the given health check.
+func (c Checker) Check(ctx context.Context, project domain.Project, check domain.HealthCheck, exec application.RemoteExecutor) error {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	seconds := int(timeout.Round(time.Second).Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	switch check.Type {
+	case domain.HealthCheckHTTP:
+		return c.checkHTTP(ctx, exec, check, seconds)
+	case domain.HealthCheckTCP:
+		return c.checkTCP(ctx, exec, check, seconds)
+	case domain.HealthCheckCommand:
+		return c.checkCommand(ctx, exec, project, check, seconds)
+	default:
+		return fmt.Errorf("unknown health check type %q", check.Type)
+	}
+}
+
+func (c Checker) checkHTTP(ctx context.Context, exec application.RemoteExecutor, check domain.HealthCheck, seconds int) error {
+	if check.URL == "" {
+		return fmt.Errorf("http health check requires a url")
+	}
+	cmd := fmt.Sprintf("curl -sS --max-time %d -w '\\n%%{http_code}' %s", seconds, shell.Escape(check.URL))
+	res, err := exec.Run(ctx, cmd)
+	if err != nil {
+		return fmt.Errorf("request %s: %w", check.URL, err)
+	}
+
+	out := res.Stdout
+	idx := strings.LastIndex(out, "\n")
+	body, code := "", strings.TrimSpace(out)
+	if idx >= 0 {
+		body, code = out[:idx], strings.TrimSpace(out[idx+1:])
+	}
+	status, err := strconv.Atoi(code)
+	if err != nil {
+		return fmt.Errorf("request %s: unexpected curl output %q", check.URL, code)
+	}
+
+	expected := check.ExpectStatus
+	if expected == 0 {
+		expected = 200
+	}
+	if status != expected {
+		return fmt.Errorf("request %s: got status %d, want %d", check.URL, status, expected)
+	}
+	if check.ExpectBody != "" && !strings.Contains(body, check.ExpectBody) {
+		return fmt.Errorf("request %s: body does not contain %q", check.URL, check.ExpectBody)
+	}
+	return nil
+}
+
+func (c Checker) checkTCP(ctx context.Context, exec application.RemoteExecutor, check domain.HealthCheck, seconds int) error {
+	if check.Port <= 0 {
+		return fmt.Errorf("tcp health check requires a port")
+	}
+	host := check.Host
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	probe := fmt.Sprintf("exec 3<>/dev/tcp/%s/%d", host, check.Port)
+	cmd := fmt.Sprintf("timeout %d bash -c %s", seconds, shell.Escape(probe))
+	_, err := exec.Run(ctx, cmd)
+	if domain.ExitStatus(err) > 0 {
+		return fmt.Errorf("%s:%d is not accepting connections", host, check.Port)
+	}
+	return err
+}
+
+func (c Checker) checkCommand(ctx context.Context, exec application.RemoteExecutor, project domain.Project, check domain.HealthCheck, seconds int) error {
+	if check.Command == "" {
+		return fmt.Errorf("command health check requires a command")
+	}
+	cmd := fmt.Sprintf("cd %s && timeout %d sh -c %s", shell.Escape(project.DeployDir), seconds, shell.Escape(check.Command))
+	if _, err := exec.Run(ctx, cmd); err != nil {
+		return fmt.Errorf("%s: %w", check.Command, err)
+	}
+	return nil
+}
+
+var _ application.HealthChecker = Checker{}
+
+// Planned records health checks in a dry-run plan instead of running them.
+type Planned struct {
+	Plan *remote.Plan
+}
+
+// Check records the check and reports it as passing.
+func (p Planned) Check(_ context.Context, _ domain.Project, check domain.HealthCheck, _ application.RemoteExecutor) error {
+	p.Plan.Record("health: %s", check.Describe())
+	return nil
+}
+
+var _ application.HealthChecker = Planned{}
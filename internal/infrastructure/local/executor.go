@@ -0,0 +1,99 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dadyutenga/git-engine/internal/application"
+	"github.com/dadyutenga/git-engine/internal/domain"
+)
+
+// stopGrace is how long a cancelled command may take to exit after SIGTERM
+// before it is killed.
+const stopGrace = 10 * time.Second
+
+// Executor implements application.RemoteExecutor on the local machine using sh.
+type Executor struct {
+	// Dir is the working directory for commands; empty means the current directory.
+	Dir string
+	// Trace, when set, receives every command before it runs and the output
+	// Run captures while the command runs.
+	Trace io.Writer
+}
+
+// Run executes a command and captures its output.
+func (e Executor) Run(ctx context.Context, command string) (domain.CommandResult, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := e.command(ctx, command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if e.Trace != nil {
+		// Both streams are copied concurrently, so they share one lock.
+		trace := &syncWriter{w: e.Trace}
+		cmd.Stdout = io.MultiWriter(&stdout, trace)
+		cmd.Stderr = io.MultiWriter(&stderr, trace)
+	}
+	start := time.Now()
+	err := cmd.Run()
+	result := domain.CommandResult{Command: command, Stdout: stdout.String(), Stderr: stderr.String(), Duration: time.Since(start)}
+	return commandError(result, err)
+}
+
+// RunStream executes a command streaming its output to writer.
+func (e Executor) RunStream(ctx context.Context, command string, writer io.Writer) error {
+	cmd := e.command(ctx, command)
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	start := time.Now()
+	err := cmd.Run()
+	_, err = commandError(domain.CommandResult{Command: command, Duration: time.Since(start)}, err)
+	return err
+}
+
+// commandError records the exit status of result and wraps a failure in a
+// *domain.RemoteCommandError.
+func commandError(result domain.CommandResult, err error) (domain.CommandResult, error) {
+	if err == nil {
+		return result, nil
+	}
+	result.ExitStatus = -1
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.Exited() {
+		result.ExitStatus = exit.ExitCode()
+	}
+	return result, &domain.RemoteCommandError{Result: result, Err: err}
+}
+
+// command prepares command so that cancelling ctx terminates it, first with
+// SIGTERM and after stopGrace by killing it.
+func (e Executor) command(ctx context.Context, command string) *exec.Cmd {
+	if e.Trace != nil {
+		fmt.Fprintf(e.Trace, "$ %s\n", command)
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = e.Dir
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = stopGrace
+	return cmd
+}
+
+// syncWriter serialises writes to w.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+var _ application.RemoteExecutor = Executor{}
@@ -0,0 +1,25 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRunTracesOutput(t *testing.T) {
+	var trace bytes.Buffer
+	res, err := Executor{Trace: &trace}.Run(context.Background(), "echo built; echo migrated >&2")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res.Stdout != "built\n" || res.Stderr != "migrated\n" {
+		t.Errorf("captured stdout %q, stderr %q", res.Stdout, res.Stderr)
+	}
+	got := trace.String()
+	for _, want := range []string{"$ echo built; echo migrated >&2\n", "built\n", "migrated\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("trace %q does not contain %q", got, want)
+		}
+	}
+}
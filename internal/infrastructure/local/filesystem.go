@@ -0,0 +1,125 @@
+package local
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/dadyutenga/git-engine/internal/application"
+)
+
+// FileSystem implements application.RemoteFileSystem on the local machine,
+// for the agent running on the server itself.
+type FileSystem struct{}
+
+// Exists reports if path exists, following symlinks.
+func (FileSystem) Exists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Mkdir creates a directory, including its parents when recursive is set.
+func (FileSystem) Mkdir(path string, recursive bool) error {
+	if recursive {
+		return os.MkdirAll(path, 0o755)
+	}
+	return os.Mkdir(path, 0o755)
+}
+
+// List returns the names of the entries within a directory.
+func (FileSystem) List(path string) ([]string, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+// ReadFile returns the content of a file.
+func (FileSystem) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+// WriteFile creates or truncates path and writes data with the given mode.
+// The mode is set on the open file before any data is written.
+func (FileSystem) WriteFile(path string, data []byte, perm fs.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// AppendFile appends data to path, creating it when missing.
+func (FileSystem) AppendFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Upload copies localPath to path.
+func (FileSystem) Upload(localPath, path string) error {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// Download copies path to localPath.
+func (fsys FileSystem) Download(path, localPath string) error {
+	return fsys.Upload(path, localPath)
+}
+
+// Stat describes path, following symlinks.
+func (FileSystem) Stat(path string) (fs.FileInfo, error) {
+	return os.Stat(path)
+}
+
+// Remove deletes a file or an empty directory.
+func (FileSystem) Remove(path string) error {
+	return os.Remove(path)
+}
+
+// Rename moves oldPath over newPath.
+func (FileSystem) Rename(oldPath, newPath string) error {
+	return os.Rename(oldPath, newPath)
+}
+
+// Symlink creates link pointing at target.
+func (FileSystem) Symlink(target, link string) error {
+	return os.Symlink(target, link)
+}
+
+var _ application.RemoteFileSystem = FileSystem{}
@@ -0,0 +1,344 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the manifest file looked up in the deployed repository.
+const FileName = "deploy.yaml"
+
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Manifest describes how a project is deployed. The same schema is used by
+// deploy.yaml in the repository and by the projects section of the CLI config.
+type Manifest struct {
+	Name         string            `yaml:"name"`
+	Branch       string            `yaml:"branch"`
+	Strategy     string            `yaml:"strategy"`
+	Paths        Paths             `yaml:"paths"`
+	Env          map[string]string `yaml:"env"`
+	HealthChecks []HealthCheck     `yaml:"healthChecks"`
+	Hooks        map[string][]Hook `yaml:"hooks"`
+	Artifact     *Artifact         `yaml:"artifact"`
+	Backups      *Backups          `yaml:"backups"`
+}
+
+// Paths overrides the remote base directories.
+type Paths struct {
+	Repo   string `yaml:"repo"`
+	Deploy string `yaml:"deploy"`
+	Backup string `yaml:"backup"`
+	Lock   string `yaml:"lock"`
+	Log    string `yaml:"log"`
+}
+
+// HealthCheck configures a post-deploy health check. Retries counts the
+// attempts after the first failure: unset means domain.DefaultHealthRetries
+// and 0 disables retries.
+type HealthCheck struct {
+	Type         string        `yaml:"type"`
+	URL          string        `yaml:"url"`
+	ExpectStatus int           `yaml:"expectStatus"`
+	ExpectBody   string        `yaml:"expectBody"`
+	Host         string        `yaml:"host"`
+	Port         int           `yaml:"port"`
+	Command      string        `yaml:"command"`
+	Retries      *int          `yaml:"retries"`
+	Interval     time.Duration `yaml:"interval"`
+	Timeout      time.Duration `yaml:"timeout"`
+}
+
+// Hook declares a command run at a lifecycle stage.
+type Hook struct {
+	Name    string `yaml:"name"`
+	Command string `yaml:"command"`
+	Local   bool   `yaml:"local"`
+	// OnError is either "abort" (default) or "warn".
+	OnError string `yaml:"onError"`
+}
+
+// Artifact enables artifact mode: the project is built locally and the
+// packaged output is uploaded instead of building on the server.
+type Artifact struct {
+	Build  string `yaml:"build"`
+	Output string `yaml:"output"`
+	Image  string `yaml:"image"`
+}
+
+// Backups configures how release backups are stored and kept. Unset fields
+// keep the defaults: gzip tarballs, the last 10 backups, no age or size limit.
+type Backups struct {
+	// Store is tarball or snapshot.
+	Store string `yaml:"store"`
+	// Keep is the number of backups kept; 0 keeps all of them.
+	Keep     *int `yaml:"keep"`
+	KeepDays int  `yaml:"keepDays"`
+	// MaxSize bounds the total size, such as "500MB" or "5GB".
+	MaxSize string `yaml:"maxSize"`
+	// Compression is gzip, zstd or none.
+	Compression string `yaml:"compression"`
+	// Include re-includes paths skipped by the strategy's default excludes.
+	Include []string `yaml:"include"`
+	// Exclude lists globs left out of backups.
+	Exclude []string `yaml:"exclude"`
+	// Database dumps the database configured in .env with every backup.
+	Database bool `yaml:"database"`
+}
+
+// Parse decodes and validates a manifest.
+func Parse(content []byte) (Manifest, error) {
+	m := Manifest{}
+	if err := yaml.Unmarshal(content, &m); err != nil {
+		return m, err
+	}
+	return m, m.Validate()
+}
+
+// Load reads the manifest at path. A missing file yields an empty manifest.
+func Load(path string) (Manifest, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Manifest{}, nil
+	}
+	if err != nil {
+		return Manifest{}, err
+	}
+	m, err := Parse(content)
+	if err != nil {
+		return m, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Validate reports configuration mistakes that would only surface mid-deploy.
+func (m Manifest) Validate() error {
+	for key := range m.Env {
+		if !envKeyPattern.MatchString(key) {
+			return fmt.Errorf("env: invalid variable name %q", key)
+		}
+	}
+	for i, hc := range m.HealthChecks {
+		switch hc.Type {
+		case domain.HealthCheckHTTP:
+			if hc.URL == "" {
+				return fmt.Errorf("healthChecks[%d]: http check requires url", i)
+			}
+		case domain.HealthCheckTCP:
+			if hc.Port <= 0 {
+				return fmt.Errorf("healthChecks[%d]: tcp check requires port", i)
+			}
+		case domain.HealthCheckCommand:
+			if hc.Command == "" {
+				return fmt.Errorf("healthChecks[%d]: command check requires command", i)
+			}
+		default:
+			return fmt.Errorf("healthChecks[%d]: unknown type %q", i, hc.Type)
+		}
+		if hc.Retries != nil && *hc.Retries < 0 {
+			return fmt.Errorf("healthChecks[%d]: retries must not be negative", i)
+		}
+	}
+	for stage, hooks := range m.Hooks {
+		if !slices.Contains(domain.HookStages, stage) {
+			return fmt.Errorf("hooks: unknown stage %q", stage)
+		}
+		for i, hook := range hooks {
+			if hook.Command == "" {
+				return fmt.Errorf("hooks.%s[%d]: command is required", stage, i)
+			}
+			if hook.OnError != "" && hook.OnError != "abort" && hook.OnError != "warn" {
+				return fmt.Errorf("hooks.%s[%d]: onError must be abort or warn", stage, i)
+			}
+		}
+	}
+	if m.Artifact != nil {
+		if m.Artifact.Build == "" {
+			return fmt.Errorf("artifact: build is required")
+		}
+		if m.Artifact.Output != "" && !filepath.IsLocal(m.Artifact.Output) {
+			return fmt.Errorf("artifact: output must be a relative path inside the repository")
+		}
+	}
+	if m.Backups != nil {
+		if m.Backups.Keep != nil && *m.Backups.Keep < 0 || m.Backups.KeepDays < 0 {
+			return fmt.Errorf("backups: keep and keepDays must not be negative")
+		}
+		if _, err := parseSize(m.Backups.MaxSize); err != nil {
+			return fmt.Errorf("backups: %w", err)
+		}
+		if s := m.Backups.Store; s != "" && s != domain.BackupStoreTarball && s != domain.BackupStoreSnapshot {
+			return fmt.Errorf("backups: store must be tarball or snapshot")
+		}
+		if c := m.Backups.Compression; c != "" && domain.BackupExtension(c) == "" {
+			return fmt.Errorf("backups: compression must be gzip, zstd or none")
+		}
+		for _, glob := range slices.Concat(m.Backups.Include, m.Backups.Exclude) {
+			if _, err := path.Match(glob, ""); err != nil || glob == "" {
+				return fmt.Errorf("backups: invalid glob %q", glob)
+			}
+		}
+	}
+	return nil
+}
+
+// Merge returns m with every field set in override taking precedence.
+func (m Manifest) Merge(override Manifest) Manifest {
+	merged := m
+	if override.Name != "" {
+		merged.Name = override.Name
+	}
+	if override.Branch != "" {
+		merged.Branch = override.Branch
+	}
+	if override.Strategy != "" {
+		merged.Strategy = override.Strategy
+	}
+	merged.Paths = Paths{
+		Repo:   orDefault(override.Paths.Repo, m.Paths.Repo),
+		Deploy: orDefault(override.Paths.Deploy, m.Paths.Deploy),
+		Backup: orDefault(override.Paths.Backup, m.Paths.Backup),
+		Lock:   orDefault(override.Paths.Lock, m.Paths.Lock),
+		Log:    orDefault(override.Paths.Log, m.Paths.Log),
+	}
+	if len(override.Env) > 0 {
+		merged.Env = make(map[string]string, len(m.Env)+len(override.Env))
+		maps.Copy(merged.Env, m.Env)
+		maps.Copy(merged.Env, override.Env)
+	}
+	if len(override.HealthChecks) > 0 {
+		merged.HealthChecks = override.HealthChecks
+	}
+	if len(override.Hooks) > 0 {
+		merged.Hooks = make(map[string][]Hook, len(m.Hooks)+len(override.Hooks))
+		maps.Copy(merged.Hooks, m.Hooks)
+		maps.Copy(merged.Hooks, override.Hooks)
+	}
+	if override.Artifact != nil {
+		merged.Artifact = override.Artifact
+	}
+	if override.Backups != nil {
+		merged.Backups = override.Backups
+	}
+	return merged
+}
+
+// Project builds the domain project described by the manifest.
+func (m Manifest) Project(name string) domain.Project {
+	project := domain.NewProjectWithPaths(name, domain.BasePaths{
+		Repo:   m.Paths.Repo,
+		Deploy: m.Paths.Deploy,
+		Backup: m.Paths.Backup,
+		Lock:   m.Paths.Lock,
+		Log:    m.Paths.Log,
+	})
+	if m.Branch != "" {
+		project.Branch = m.Branch
+	}
+	project.Strategy = m.Strategy
+	project.Env = m.Env
+	for _, hc := range m.HealthChecks {
+		retries := domain.DefaultHealthRetries
+		if hc.Retries != nil {
+			retries = *hc.Retries
+		}
+		project.HealthChecks = append(project.HealthChecks, domain.HealthCheck{
+			Type:         hc.Type,
+			URL:          hc.URL,
+			ExpectStatus: hc.ExpectStatus,
+			ExpectBody:   hc.ExpectBody,
+			Host:         hc.Host,
+			Port:         hc.Port,
+			Command:      hc.Command,
+			Retries:      retries,
+			Interval:     hc.Interval,
+			Timeout:      hc.Timeout,
+		})
+	}
+	for stage, hooks := range m.Hooks {
+		if project.Hooks == nil {
+			project.Hooks = map[string][]domain.Hook{}
+		}
+		for _, hook := range hooks {
+			project.Hooks[stage] = append(project.Hooks[stage], domain.Hook{
+				Name:    hook.Name,
+				Command: hook.Command,
+				Local:   hook.Local,
+				Warn:    hook.OnError == "warn",
+			})
+		}
+	}
+	if m.Artifact != nil {
+		project.Artifact = &domain.ArtifactBuild{Command: m.Artifact.Build, Output: m.Artifact.Output, Image: m.Artifact.Image}
+	}
+	if m.Backups != nil {
+		// Validate has already rejected sizes that do not parse.
+		maxSize, _ := parseSize(m.Backups.MaxSize)
+		retention := project.Backups.Retention
+		if m.Backups.Keep != nil {
+			retention.KeepLast = *m.Backups.Keep
+		}
+		retention.MaxAge = time.Duration(m.Backups.KeepDays) * 24 * time.Hour
+		retention.MaxSize = maxSize
+		project.Backups.Retention = retention
+		if m.Backups.Store != "" {
+			project.Backups.Store = m.Backups.Store
+		}
+		if m.Backups.Compression != "" {
+			project.Backups.Compression = m.Backups.Compression
+		}
+		project.Backups.Database = m.Backups.Database
+		project.Backups.Include = m.Backups.Include
+		project.Backups.Exclude = m.Backups.Exclude
+	}
+	return project
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// sizeUnits are the suffixes accepted by parseSize, longest first.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+	{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+}
+
+// parseSize reads a byte count such as "1500", "500MB" or "5G"; units are
+// powers of 1024. An empty value is zero.
+func parseSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	if s == "" {
+		return 0, nil
+	}
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s, unit = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(n * float64(unit)), nil
+}
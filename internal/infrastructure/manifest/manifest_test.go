@@ -0,0 +1,170 @@
+package manifest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dadyutenga/git-engine/internal/domain"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"1500", 1500, false},
+		{"512B", 512, false},
+		{"1K", 1 << 10, false},
+		{"1kb", 1 << 10, false},
+		{"500MB", 500 << 20, false},
+		{"5G", 5 << 30, false},
+		{" 2 GB ", 2 << 30, false},
+		{"1.5G", 3 << 29, false},
+		{"1TB", 1 << 40, false},
+		{"-1G", 0, true},
+		{"lots", 0, true},
+		{"5 PB", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSize(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseSize(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{"empty", ``, ""},
+		{"complete", `
+env: {NODE_ENV: production}
+healthChecks:
+  - {type: http, url: "http://127.0.0.1/health"}
+  - {type: tcp, port: 5432}
+  - {type: command, command: "php artisan about", retries: 0}
+hooks:
+  pre-deploy: [{command: "php artisan migrate --force", onError: warn}]
+artifact: {build: "npm run build", output: dist}
+backups: {store: snapshot, keep: 0, maxSize: 5GB, compression: zstd, exclude: ["*.log"]}
+`, ""},
+		{"env name", `env: {"NODE-ENV": production}`, `invalid variable name`},
+		{"http without url", `healthChecks: [{type: http}]`, "healthChecks[0]: http check requires url"},
+		{"tcp without port", `healthChecks: [{type: tcp}]`, "healthChecks[0]: tcp check requires port"},
+		{"command without command", `healthChecks: [{type: command}]`, "healthChecks[0]: command check requires command"},
+		{"unknown check", `healthChecks: [{type: ping}]`, `unknown type "ping"`},
+		{"negative retries", `healthChecks: [{type: tcp, port: 80, retries: -1}]`, "retries must not be negative"},
+		{"unknown hook stage", `hooks: {pre-build: [{command: "true"}]}`, `unknown stage "pre-build"`},
+		{"hook without command", `hooks: {post-deploy: [{name: warm}]}`, "hooks.post-deploy[0]: command is required"},
+		{"hook onError", `hooks: {post-deploy: [{command: "true", onError: ignore}]}`, "onError must be abort or warn"},
+		{"artifact without build", `artifact: {output: dist}`, "artifact: build is required"},
+		{"artifact output outside", `artifact: {build: make, output: ../dist}`, "output must be a relative path"},
+		{"negative keep", `backups: {keep: -1}`, "must not be negative"},
+		{"max size", `backups: {maxSize: lots}`, `invalid size "lots"`},
+		{"store", `backups: {store: s3}`, "store must be tarball or snapshot"},
+		{"compression", `backups: {compression: bzip2}`, "compression must be gzip, zstd or none"},
+		{"glob", `backups: {exclude: ["[a-"]}`, "invalid glob"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.yaml))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Parse() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("Parse() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	keep := 3
+	base := Manifest{
+		Name:         "shop",
+		Branch:       "main",
+		Strategy:     "node",
+		Paths:        Paths{Deploy: "/srv/www", Log: "/var/log/deploy"},
+		Env:          map[string]string{"NODE_ENV": "production", "PORT": "3000"},
+		HealthChecks: []HealthCheck{{Type: "tcp", Port: 3000}},
+		Hooks:        map[string][]Hook{"pre-deploy": {{Command: "npm test"}}},
+	}
+	tests := []struct {
+		name     string
+		override Manifest
+		want     Manifest
+	}{
+		{"empty override", Manifest{}, base},
+		{
+			name:     "scalars and paths",
+			override: Manifest{Branch: "release", Paths: Paths{Deploy: "/var/www", Backup: "/backups"}},
+			want: Manifest{
+				Name: "shop", Branch: "release", Strategy: "node",
+				Paths:        Paths{Deploy: "/var/www", Backup: "/backups", Log: "/var/log/deploy"},
+				Env:          base.Env,
+				HealthChecks: base.HealthChecks,
+				Hooks:        base.Hooks,
+			},
+		},
+		{
+			name: "maps merge per key, lists replace",
+			override: Manifest{
+				Env:          map[string]string{"PORT": "8080"},
+				HealthChecks: []HealthCheck{{Type: "http", URL: "http://127.0.0.1:8080"}},
+				Hooks:        map[string][]Hook{"post-deploy": {{Command: "npm run warm"}}},
+				Backups:      &Backups{Keep: &keep},
+			},
+			want: Manifest{
+				Name: "shop", Branch: "main", Strategy: "node",
+				Paths:        base.Paths,
+				Env:          map[string]string{"NODE_ENV": "production", "PORT": "8080"},
+				HealthChecks: []HealthCheck{{Type: "http", URL: "http://127.0.0.1:8080"}},
+				Hooks:        map[string][]Hook{"pre-deploy": {{Command: "npm test"}}, "post-deploy": {{Command: "npm run warm"}}},
+				Backups:      &Backups{Keep: &keep},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := base.Merge(tt.override); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if base.Env["PORT"] != "3000" {
+		t.Errorf("Merge() modified the base manifest's env")
+	}
+}
+
+func TestProjectHealthCheckRetries(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want int
+	}{
+		{"unset", `healthChecks: [{type: tcp, port: 80}]`, domain.DefaultHealthRetries},
+		{"zero", `healthChecks: [{type: tcp, port: 80, retries: 0}]`, 0},
+		{"set", `healthChecks: [{type: tcp, port: 80, retries: 5}]`, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse([]byte(tt.yaml))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.Project("shop").HealthChecks[0].Retries; got != tt.want {
+				t.Errorf("Retries = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,363 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/dadyutenga/git-engine/internal/application"
+	"github.com/dadyutenga/git-engine/internal/domain"
+)
+
+// Plan records the commands a dry run would have executed, in order.
+type Plan struct {
+	mu    sync.Mutex
+	steps []string
+}
+
+// Record appends a step to the plan.
+func (p *Plan) Record(format string, args ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.steps = append(p.steps, fmt.Sprintf(format, args...))
+}
+
+// RecordOnce appends a step unless the same step was already recorded.
+func (p *Plan) RecordOnce(format string, args ...any) {
+	step := fmt.Sprintf(format, args...)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !slices.Contains(p.steps, step) {
+		p.steps = append(p.steps, step)
+	}
+}
+
+// Steps returns the recorded steps.
+func (p *Plan) Steps() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.steps...)
+}
+
+// Alias rewrites paths in read-only probes, e.g. to answer questions about a
+// release directory that a dry run never creates from the source checkout.
+type Alias struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// ReleaseSourceAlias answers probes about a release directory, which a dry run
+// never exports, from the project's source checkout that would have been exported.
+var ReleaseSourceAlias = Alias{
+	Pattern:     regexp.MustCompile(`/releases/[0-9]{14}\b`),
+	Replacement: "/repo",
+}
+
+// DryRunExecutor implements application.RemoteExecutor by recording commands
+// instead of running them. Read-only probes are forwarded to Probe so that
+// decisions such as strategy detection reflect the real host.
+type DryRunExecutor struct {
+	Plan *Plan
+	// Probe answers read-only commands; nil answers every probe with empty output.
+	Probe application.RemoteExecutor
+	// Label prefixes recorded steps, e.g. "remote" or "local".
+	Label   string
+	Aliases []Alias
+}
+
+// Run records mutating commands and forwards read-only probes. User commands
+// are always recorded.
+func (d DryRunExecutor) Run(ctx context.Context, command string) (domain.CommandResult, error) {
+	if !application.IsUserCommand(ctx) && IsReadOnly(command) {
+		if d.Probe == nil {
+			return domain.CommandResult{Command: command}, nil
+		}
+		return d.Probe.Run(ctx, d.rewrite(command))
+	}
+	d.Plan.Record("%s: %s", d.Label, command)
+	return domain.CommandResult{Command: command}, nil
+}
+
+// RunStream records mutating commands and streams read-only ones.
+func (d DryRunExecutor) RunStream(ctx context.Context, command string, writer io.Writer) error {
+	if !application.IsUserCommand(ctx) && IsReadOnly(command) && d.Probe != nil {
+		return d.Probe.RunStream(ctx, d.rewrite(command), writer)
+	}
+	d.Plan.Record("%s: %s", d.Label, command)
+	return nil
+}
+
+func (d DryRunExecutor) rewrite(command string) string {
+	return rewrite(d.Aliases, command)
+}
+
+func rewrite(aliases []Alias, s string) string {
+	for _, alias := range aliases {
+		s = alias.Pattern.ReplaceAllLiteralString(s, alias.Replacement)
+	}
+	return s
+}
+
+// DryRunLock pretends to acquire deployment locks and records doing so.
+type DryRunLock struct {
+	Plan *Plan
+}
+
+// Acquire records the lock acquisition and reports success.
+func (l DryRunLock) Acquire(ctx context.Context, project domain.Project) (bool, error) {
+	l.Plan.Record("lock: acquire %s", project.LockFile)
+	return true, nil
+}
+
+// Release records the lock release.
+func (l DryRunLock) Release(ctx context.Context, project domain.Project) error {
+	l.Plan.Record("lock: release %s", project.LockFile)
+	return nil
+}
+
+// readOnlyCommands are the programs a dry run is allowed to execute.
+var readOnlyCommands = map[string]bool{
+	"test": true, "[": true, "ls": true, "readlink": true, "id": true, "cat": true,
+	"echo": true, "export": true, "tail": true, "stat": true, "du": true, "true": true, "cd": true,
+	"find": true, "grep": true, "head": true, "wc": true, "sha256sum": true,
+}
+
+// readOnlySubcommands are read-only invocations of otherwise mutating tools.
+var readOnlySubcommands = []string{
+	"systemctl is-active", "pm2 describe", "docker compose ps",
+	"git rev-parse", "git show", "git ls-remote", "git cat-file", "git merge-base", "git log", "git for-each-ref",
+}
+
+// IsReadOnly reports whether a shell command only inspects remote state.
+// Every pipeline segment must start with a known read-only program and the
+// command may only redirect output to /dev/null.
+func IsReadOnly(command string) bool {
+	segments, ok := splitSegments(command)
+	if !ok {
+		return false
+	}
+	for _, segment := range segments {
+		fields := strings.Fields(segment)
+		for len(fields) > 0 && fields[0] == "!" {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "find" && slices.ContainsFunc(fields, isFindAction) {
+			return false
+		}
+		if fields[0] == "git" && slices.ContainsFunc(fields, func(f string) bool { return strings.HasPrefix(strings.Trim(f, `'"`), "--output") }) {
+			return false
+		}
+		if readOnlyCommands[fields[0]] {
+			continue
+		}
+		if !hasReadOnlySubcommand(fields) {
+			return false
+		}
+	}
+	return true
+}
+
+// isFindAction reports whether a find argument runs commands or writes files.
+func isFindAction(field string) bool {
+	field = strings.Trim(field, `'"`)
+	switch field {
+	case "-exec", "-execdir", "-ok", "-okdir", "-delete":
+		return true
+	}
+	return strings.HasPrefix(field, "-fprint") || strings.HasPrefix(field, "-fls")
+}
+
+// hasReadOnlySubcommand reports whether fields invoke a read-only subcommand.
+// The subcommand must be the first words after the program that are not
+// flags; the values of the global flags in flagValues are skipped and
+// configuration overrides such as "git -c" count as mutating.
+func hasReadOnlySubcommand(fields []string) bool {
+	if len(fields) == 0 {
+		return false
+	}
+	var words []string
+	for i := 1; i < len(fields); i++ {
+		f := strings.Trim(fields[i], `'"`)
+		if !strings.HasPrefix(f, "-") {
+			words = append(words, f)
+			continue
+		}
+		if f == "-c" || strings.HasPrefix(f, "--config") || fields[0] == "git" && strings.HasPrefix(f, "-c") {
+			return false
+		}
+		if slices.Contains(flagValues[fields[0]], f) {
+			i++
+		}
+	}
+	for _, sub := range readOnlySubcommands {
+		parts := strings.Fields(sub)
+		if fields[0] == parts[0] && len(words) >= len(parts)-1 && slices.Equal(words[:len(parts)-1], parts[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// flagValues are the global flags of readOnlySubcommands programs that take
+// the next word as their value.
+var flagValues = map[string][]string{
+	"git":       {"-C", "--git-dir", "--work-tree", "--namespace"},
+	"docker":    {"-p", "--project-name", "-f", "--file", "--project-directory", "--env-file", "--profile", "--context", "-H", "--host"},
+	"systemctl": {"-H", "--host", "-M", "--machine", "-t", "--type", "--state", "-p", "--property"},
+}
+
+// splitSegments splits a command on shell control operators and newlines
+// outside single quotes. It fails if the command writes to a file or substitutes commands.
+func splitSegments(command string) ([]string, bool) {
+	var segments []string
+	var current strings.Builder
+	inQuote := false
+	for i := 0; i < len(command); i++ {
+		ch := command[i]
+		if ch == '\\' && !inQuote && i+1 < len(command) {
+			current.WriteByte(ch)
+			current.WriteByte(command[i+1])
+			i++
+			continue
+		}
+		if ch == '\'' {
+			inQuote = !inQuote
+			current.WriteByte(ch)
+			continue
+		}
+		if inQuote {
+			current.WriteByte(ch)
+			continue
+		}
+		switch ch {
+		case '&', '|', ';', '\n':
+			segments = append(segments, current.String())
+			current.Reset()
+		case '`':
+			return nil, false
+		case '$':
+			if i+1 < len(command) && command[i+1] == '(' {
+				return nil, false
+			}
+			current.WriteByte(ch)
+		case '>':
+			rest := strings.TrimLeft(command[i+1:], "> ")
+			if !strings.HasPrefix(rest, "/dev/null") {
+				return nil, false
+			}
+			i += len(command[i+1:]) - len(rest) + len("/dev/null")
+		case '(', ')':
+			current.WriteByte(' ')
+		default:
+			current.WriteByte(ch)
+		}
+	}
+	segments = append(segments, current.String())
+	return segments, true
+}
+
+var (
+	_ application.RemoteExecutor = DryRunExecutor{}
+	_ application.LockManager    = DryRunLock{}
+)
+
+// DryRunFileSystem implements application.RemoteFileSystem by recording
+// changes instead of making them. Reads are forwarded to Probe with the
+// dry-run aliases applied.
+type DryRunFileSystem struct {
+	Plan *Plan
+	// Probe answers reads; nil reports every path as missing.
+	Probe   application.RemoteFileSystem
+	Aliases []Alias
+}
+
+// Exists forwards to Probe.
+func (d DryRunFileSystem) Exists(path string) (bool, error) {
+	if d.Probe == nil {
+		return false, nil
+	}
+	return d.Probe.Exists(rewrite(d.Aliases, path))
+}
+
+// List forwards to Probe.
+func (d DryRunFileSystem) List(path string) ([]string, error) {
+	if d.Probe == nil {
+		return []string{}, nil
+	}
+	return d.Probe.List(rewrite(d.Aliases, path))
+}
+
+// ReadFile forwards to Probe.
+func (d DryRunFileSystem) ReadFile(path string) ([]byte, error) {
+	if d.Probe == nil {
+		return nil, fs.ErrNotExist
+	}
+	return d.Probe.ReadFile(rewrite(d.Aliases, path))
+}
+
+// Stat forwards to Probe.
+func (d DryRunFileSystem) Stat(path string) (fs.FileInfo, error) {
+	if d.Probe == nil {
+		return nil, fs.ErrNotExist
+	}
+	return d.Probe.Stat(rewrite(d.Aliases, path))
+}
+
+// Mkdir records the directory creation.
+func (d DryRunFileSystem) Mkdir(path string, recursive bool) error {
+	d.Plan.Record("remote fs: mkdir %s", path)
+	return nil
+}
+
+// WriteFile records the write.
+func (d DryRunFileSystem) WriteFile(path string, data []byte, perm fs.FileMode) error {
+	d.Plan.Record("remote fs: write %s (%d bytes, mode %s)", path, len(data), perm)
+	return nil
+}
+
+// AppendFile records the first append to path; appends are typically
+// repeated log writes.
+func (d DryRunFileSystem) AppendFile(path string, data []byte) error {
+	d.Plan.RecordOnce("remote fs: append to %s", path)
+	return nil
+}
+
+// Upload records the upload.
+func (d DryRunFileSystem) Upload(localPath, path string) error {
+	d.Plan.Record("remote fs: upload %s to %s", localPath, path)
+	return nil
+}
+
+// Download records the download.
+func (d DryRunFileSystem) Download(path, localPath string) error {
+	d.Plan.Record("remote fs: download %s to %s", path, localPath)
+	return nil
+}
+
+// Remove records the removal.
+func (d DryRunFileSystem) Remove(path string) error {
+	d.Plan.Record("remote fs: remove %s", path)
+	return nil
+}
+
+// Rename records the rename.
+func (d DryRunFileSystem) Rename(oldPath, newPath string) error {
+	d.Plan.Record("remote fs: rename %s to %s", oldPath, newPath)
+	return nil
+}
+
+// Symlink records the link creation.
+func (d DryRunFileSystem) Symlink(target, link string) error {
+	d.Plan.Record("remote fs: symlink %s -> %s", link, target)
+	return nil
+}
+
+var _ application.RemoteFileSystem = DryRunFileSystem{}
@@ -0,0 +1,112 @@
+package remote
+
+import (
+	"context"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/dadyutenga/git-engine/internal/application"
+	"github.com/dadyutenga/git-engine/internal/domain"
+)
+
+func TestIsReadOnly(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"readlink '/var/www/app/current' || true", true},
+		{"test -f /var/www/app/current/package.json", true},
+		{"cd '/var/www/app/repo' && git rev-parse --verify --quiet HEAD", true},
+		{"git -C /var/www/app/repo ls-remote origin main", true},
+		{"systemctl is-active --quiet php-fpm", true},
+		{"docker compose -p app ps --status running", true},
+		{"find /var/www/app/releases -maxdepth 1 -type d", true},
+		{"cat /var/www/app/history.jsonl 2>/dev/null", true},
+		{"echo 'abc  /tmp/x' | sha256sum -c --status", true},
+		{"! test -e /var/www/app/current", true},
+		{"echo 'a > b'", true},
+
+		{"rm -rf /var/www/app/releases/1", false},
+		{"mkdir -p /var/www/app/shared", false},
+		{"git fetch origin main", false},
+		{"systemctl restart php-fpm", false},
+		{"echo hi > /tmp/file", false},
+		{"cat /etc/passwd >> /tmp/copy", false},
+		{"echo $(rm -rf /)", false},
+		{"echo `rm -rf /`", false},
+		{"ls && rm -rf /tmp/x", false},
+		{"ls | xargs rm", false},
+		{"ls; touch /tmp/x", false},
+		{"ls\ntouch /tmp/x", false},
+		{"find /var/www -name '*.log' -delete", false},
+		{"find /var/www -name '*.log' '-delete'", false},
+		{"find /var/www -exec rm {} ;", false},
+		{"find /var/www -execdir rm {} +", false},
+		{"find /var/www -ok rm {} ;", false},
+		{"find /var/www -fprint /tmp/list", false},
+		{"find /var/www -fls /tmp/list", false},
+		{"git log --output=/tmp/log", false},
+		{"git show HEAD '--output=/tmp/x'", false},
+		{"git push origin show", false},
+		{"git -C /srv/app push origin log", false},
+		{"systemctl restart app is-active", false},
+		{"docker compose rm ps", false},
+		{"docker compose -p app rm ps", false},
+		{"git -c core.sshCommand=evil ls-remote origin", false},
+		{"git -ccore.pager=evil log", false},
+		{"git --config-env=core.sshCommand=X ls-remote origin", false},
+		{"pm2 delete describe", false},
+		{"git -C /srv/app show HEAD:deploy.yaml", true},
+		{"git --git-dir /srv/app.git log -1", true},
+		{"docker compose -f compose.yaml -p app ps", true},
+		{"systemctl --quiet is-active app", true},
+	}
+	for _, tt := range tests {
+		if got := IsReadOnly(tt.command); got != tt.want {
+			t.Errorf("IsReadOnly(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
+
+type probeRecorder struct {
+	commands []string
+}
+
+func (p *probeRecorder) Run(ctx context.Context, command string) (domain.CommandResult, error) {
+	p.commands = append(p.commands, command)
+	return domain.CommandResult{Command: command}, nil
+}
+
+func (p *probeRecorder) RunStream(ctx context.Context, command string, writer io.Writer) error {
+	p.commands = append(p.commands, command)
+	return nil
+}
+
+func TestDryRunExecutorRecordsUserCommands(t *testing.T) {
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		command   string
+		wantProbe bool
+	}{
+		{"read-only probe", context.Background(), "test -f /var/www/app/current/artisan", true},
+		{"mutating command", context.Background(), "rm -rf /var/www/app/releases/1", false},
+		{"read-only user command", application.UserCommand(context.Background()), "cat /var/www/app/.env", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe := &probeRecorder{}
+			d := DryRunExecutor{Plan: &Plan{}, Probe: probe, Label: "remote"}
+			if _, err := d.Run(tt.ctx, tt.command); err != nil {
+				t.Fatal(err)
+			}
+			if probed := slices.Contains(probe.commands, tt.command); probed != tt.wantProbe {
+				t.Errorf("probed = %v, want %v", probed, tt.wantProbe)
+			}
+			if recorded := len(d.Plan.Steps()) == 1; recorded == tt.wantProbe {
+				t.Errorf("recorded = %v, want %v", recorded, !tt.wantProbe)
+			}
+		})
+	}
+}
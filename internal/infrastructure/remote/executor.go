@@ -1,9 +1,15 @@
 package remote
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"io"
+	"log"
+	"time"
 
 	"github.com/dadyutenga/git-engine/internal/application"
+	"github.com/dadyutenga/git-engine/internal/domain"
 	sshclient "github.com/dadyutenga/git-engine/internal/infrastructure/ssh"
 )
 
@@ -12,14 +18,46 @@ type Executor struct {
 	Client *sshclient.Client
 }
 
-// Run executes a remote command.
-func (e Executor) Run(command string) (string, error) {
-	return e.Client.Run(command)
+// Run executes a remote command. Commands marked with application.Retryable
+// that are interrupted by a lost connection run once more over a new
+// connection; any other command is never retried.
+func (e Executor) Run(ctx context.Context, command string) (domain.CommandResult, error) {
+	result, err := e.run(ctx, command)
+	if application.IsRetryable(ctx) && errors.Is(err, sshclient.ErrConnectionLost) {
+		log.Printf("WARNING: connection lost while running %q; retrying", command)
+		result, err = e.run(ctx, command)
+	}
+	return result, err
+}
+
+func (e Executor) run(ctx context.Context, command string) (domain.CommandResult, error) {
+	var stdout, stderr bytes.Buffer
+	start := time.Now()
+	err := e.Client.Run(ctx, command, &stdout, &stderr)
+	result := domain.CommandResult{Command: command, Stdout: stdout.String(), Stderr: stderr.String(), Duration: time.Since(start)}
+	return commandError(result, err)
 }
 
 // RunStream streams command output to the provided writer.
-func (e Executor) RunStream(command string, writer io.Writer) error {
-	return e.Client.RunStream(command, writer)
+func (e Executor) RunStream(ctx context.Context, command string, writer io.Writer) error {
+	start := time.Now()
+	err := e.Client.RunStream(ctx, command, writer)
+	_, err = commandError(domain.CommandResult{Command: command, Duration: time.Since(start)}, err)
+	return err
+}
+
+// commandError records the exit status of result and wraps a failure in a
+// *domain.RemoteCommandError.
+func commandError(result domain.CommandResult, err error) (domain.CommandResult, error) {
+	if err == nil {
+		return result, nil
+	}
+	status, ok := sshclient.ExitStatus(err)
+	if !ok {
+		status = -1
+	}
+	result.ExitStatus = status
+	return result, &domain.RemoteCommandError{Result: result, Err: err}
 }
 
 var _ application.RemoteExecutor = Executor{}
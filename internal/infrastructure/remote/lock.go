@@ -1,6 +1,7 @@
 package remote
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strings"
@@ -18,39 +19,42 @@ type LockManager struct {
 // Acquire tries to acquire a lock for the project.
 // If the existing lock is stale (older than 60 minutes), it is removed and
 // a single retry is attempted.
-func (l LockManager) Acquire(project domain.Project) (bool, error) {
-	cmd := `sh -c 'if ( set -o noclobber; echo $$ > ` + shell.Escape(project.LockFile) + ` ) 2>/dev/null; then echo acquired; else echo busy; fi'`
-	out, err := l.Exec.Run(cmd)
-	if err != nil {
-		return false, err
-	}
-	if strings.Contains(out, "acquired") {
-		return true, nil
+func (l LockManager) Acquire(ctx context.Context, project domain.Project) (bool, error) {
+	acquired, err := l.tryLock(ctx, project)
+	if acquired || err != nil {
+		return acquired, err
 	}
 
 	// Check if the existing lock is stale (older than 60 minutes).
 	checkStale := fmt.Sprintf("find %s -mmin +60 2>/dev/null", shell.Escape(project.LockFile))
-	staleOut, staleErr := l.Exec.Run(checkStale)
+	staleOut, staleErr := l.Exec.Run(ctx, checkStale)
 	if staleErr != nil {
 		log.Printf("WARNING: failed to check lock staleness for %s: %v", project.LockFile, staleErr)
 		return false, nil
 	}
-	if strings.TrimSpace(staleOut) == "" {
+	if strings.TrimSpace(staleOut.Stdout) == "" {
 		return false, nil
 	}
 
 	// Lock is stale; remove it and retry once.
-	_ = l.Release(project)
-	out, err = l.Exec.Run(cmd)
-	if err != nil {
-		return false, err
+	_ = l.Release(ctx, project)
+	return l.tryLock(ctx, project)
+}
+
+// tryLock creates the lock file unless it exists; the command exits with
+// status 1 when another deployment holds the lock.
+func (l LockManager) tryLock(ctx context.Context, project domain.Project) (bool, error) {
+	cmd := `sh -c '( set -o noclobber; echo $$ > ` + shell.Escape(project.LockFile) + ` ) 2>/dev/null || exit 1'`
+	_, err := l.Exec.Run(ctx, cmd)
+	if domain.ExitStatus(err) == 1 {
+		return false, nil
 	}
-	return strings.Contains(out, "acquired"), nil
+	return err == nil, err
 }
 
 // Release frees the lock file.
-func (l LockManager) Release(project domain.Project) error {
-	_, err := l.Exec.Run("rm -f " + shell.Escape(project.LockFile))
+func (l LockManager) Release(ctx context.Context, project domain.Project) error {
+	_, err := l.Exec.Run(ctx, "rm -f "+shell.Escape(project.LockFile))
 	return err
 }
 
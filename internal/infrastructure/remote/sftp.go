@@ -0,0 +1,192 @@
+package remote
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/dadyutenga/git-engine/internal/application"
+	sshclient "github.com/dadyutenga/git-engine/internal/infrastructure/ssh"
+)
+
+// SFTPFileSystem implements application.RemoteFileSystem over an SFTP
+// session sharing the deployment's SSH connection. The session follows the
+// client across reconnects.
+type SFTPFileSystem struct {
+	Client *sshclient.Client
+}
+
+// Exists reports if path exists remotely, following symlinks.
+func (s SFTPFileSystem) Exists(path string) (bool, error) {
+	_, err := s.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Mkdir creates a directory, including its parents when recursive is set.
+func (s SFTPFileSystem) Mkdir(path string, recursive bool) error {
+	c, err := s.Client.SFTP()
+	if err != nil {
+		return err
+	}
+	if recursive {
+		return c.MkdirAll(path)
+	}
+	return c.Mkdir(path)
+}
+
+// List returns the names of the entries within a directory.
+func (s SFTPFileSystem) List(path string) ([]string, error) {
+	c, err := s.Client.SFTP()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := c.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+// ReadFile returns the content of a remote file.
+func (s SFTPFileSystem) ReadFile(path string) ([]byte, error) {
+	c, err := s.Client.SFTP()
+	if err != nil {
+		return nil, err
+	}
+	f, err := c.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// WriteFile creates or truncates path and writes data with the given mode.
+// The mode is set on the open file before any data is written.
+func (s SFTPFileSystem) WriteFile(path string, data []byte, perm fs.FileMode) error {
+	c, err := s.Client.SFTP()
+	if err != nil {
+		return err
+	}
+	f, err := c.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// AppendFile appends data to path, creating it when missing.
+func (s SFTPFileSystem) AppendFile(path string, data []byte) error {
+	c, err := s.Client.SFTP()
+	if err != nil {
+		return err
+	}
+	f, err := c.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Upload copies a local file to path.
+func (s SFTPFileSystem) Upload(localPath, path string) error {
+	c, err := s.Client.SFTP()
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := c.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := dst.ReadFrom(src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// Download copies path to a local file.
+func (s SFTPFileSystem) Download(path, localPath string) error {
+	c, err := s.Client.SFTP()
+	if err != nil {
+		return err
+	}
+	src, err := c.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	if _, err := src.WriteTo(dst); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// Stat describes path, following symlinks.
+func (s SFTPFileSystem) Stat(path string) (fs.FileInfo, error) {
+	c, err := s.Client.SFTP()
+	if err != nil {
+		return nil, err
+	}
+	return c.Stat(path)
+}
+
+// Remove deletes a file or an empty directory.
+func (s SFTPFileSystem) Remove(path string) error {
+	c, err := s.Client.SFTP()
+	if err != nil {
+		return err
+	}
+	return c.Remove(path)
+}
+
+// Rename moves oldPath over newPath using the posix-rename extension so an
+// existing newPath is replaced atomically.
+func (s SFTPFileSystem) Rename(oldPath, newPath string) error {
+	c, err := s.Client.SFTP()
+	if err != nil {
+		return err
+	}
+	return c.PosixRename(oldPath, newPath)
+}
+
+// Symlink creates link pointing at target.
+func (s SFTPFileSystem) Symlink(target, link string) error {
+	c, err := s.Client.SFTP()
+	if err != nil {
+		return err
+	}
+	return c.Symlink(target, link)
+}
+
+var _ application.RemoteFileSystem = SFTPFileSystem{}
@@ -0,0 +1,81 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dadyutenga/git-engine/internal/application"
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"github.com/dadyutenga/git-engine/internal/infrastructure/ssh"
+	"github.com/dadyutenga/git-engine/internal/shared/shell"
+)
+
+// Pusher implements application.SourcePusher by running git push from the
+// local working repository over the SSH credentials of the target host.
+type Pusher struct {
+	SSH ssh.Config
+}
+
+// Push sends the deployed branch and all tags to the project's bare repository.
+// The push carries the skip option so the post-receive hook does not start a
+// second deployment.
+func (p Pusher) Push(ctx context.Context, project domain.Project, local application.RemoteExecutor) error {
+	if len(p.SSH.KeyPaths()) == 0 && !p.SSH.AgentEnabled() {
+		return fmt.Errorf("pushing sources requires a private key or ssh-agent; git cannot reuse password authentication")
+	}
+
+	branch := "refs/heads/" + project.Branch
+	if _, err := local.Run(ctx, fmt.Sprintf("git rev-parse --verify --quiet %s", shell.Escape(branch))); err != nil {
+		return fmt.Errorf("local branch %s not found: %w", project.Branch, err)
+	}
+
+	command := fmt.Sprintf("GIT_SSH_COMMAND=%s git push --tags -o %s %s %s",
+		shell.Escape(p.sshCommand()), shell.Escape(application.SkipPushOption), shell.Escape(p.url(project)), shell.Escape(branch+":"+branch))
+	if _, err := local.Run(ctx, command); err != nil {
+		return fmt.Errorf("git push: %w", err)
+	}
+	return nil
+}
+
+// url addresses the bare repository on the target host. User and port are
+// left out when unset so ssh resolves them from its own config.
+func (p Pusher) url(project domain.Project) string {
+	host := p.SSH.Host
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if p.SSH.User != "" {
+		host = p.SSH.User + "@" + host
+	}
+	if p.SSH.Port != 0 {
+		host = fmt.Sprintf("%s:%d", host, p.SSH.Port)
+	}
+	return "ssh://" + host + project.RepoPath
+}
+
+// sshCommand builds the ssh invocation git uses, pinned to the configured keys,
+// jump hosts and known_hosts file. Without key files ssh falls back to the
+// agent; settings left unset come from the OpenSSH config as for the CLI.
+func (p Pusher) sshCommand() string {
+	args := []string{"ssh"}
+	if p.SSH.ConfigFile != "" {
+		args = append(args, "-F", shell.Escape(ssh.ExpandHome(p.SSH.ConfigFile)))
+	}
+	if p.SSH.ProxyJump != "" {
+		args = append(args, "-J", shell.Escape(p.SSH.ProxyJump))
+	}
+	keys := p.SSH.KeyPaths()
+	if len(keys) > 0 && !p.SSH.AgentEnabled() {
+		args = append(args, "-o", "IdentitiesOnly=yes")
+	}
+	for _, key := range keys {
+		args = append(args, "-i", shell.Escape(key))
+	}
+	if p.SSH.KnownHostsPath != "" {
+		args = append(args, "-o", shell.Escape("UserKnownHostsFile="+ssh.ExpandHome(p.SSH.KnownHostsPath)), "-o", "StrictHostKeyChecking=yes")
+	}
+	return strings.Join(args, " ")
+}
+
+var _ application.SourcePusher = Pusher{}
@@ -0,0 +1,210 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/term"
+)
+
+// passphrases caches passphrases entered interactively so that connecting to
+// several hosts prompts once per key.
+var passphrases = struct {
+	sync.Mutex
+	byPath map[string][]byte
+}{byPath: map[string][]byte{}}
+
+// ExpandHome replaces a leading "~" with the current user's home directory.
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// KeyPaths returns the configured private key files in the order they are tried.
+func (c Config) KeyPaths() []string {
+	var paths []string
+	if c.PrivateKeyPath != "" {
+		paths = append(paths, ExpandHome(c.PrivateKeyPath))
+	}
+	for _, p := range c.PrivateKeyPaths {
+		paths = append(paths, ExpandHome(p))
+	}
+	return paths
+}
+
+// AgentEnabled reports whether ssh-agent authentication is configured and available.
+func (c Config) AgentEnabled() bool {
+	return (c.Agent == nil || *c.Agent) && os.Getenv("SSH_AUTH_SOCK") != ""
+}
+
+// authMethods returns the authentication methods in the order they are tried:
+// the key files in configuration order, each preceded by its OpenSSH
+// certificate ("<key>-cert.pub") when one exists, then the identities held by
+// ssh-agent, then the password. An unreachable agent is skipped with a
+// warning. It also returns the key and agent signers for jump hosts; the
+// returned closer releases the agent connection.
+func authMethods(cfg Config) ([]gossh.AuthMethod, []gossh.Signer, io.Closer, error) {
+	signers, err := loadKeys(cfg.KeyPaths(), cfg.PassphraseEnv)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var closer io.Closer
+	if cfg.AgentEnabled() {
+		agentSigners, conn, err := agentIdentities(os.Getenv("SSH_AUTH_SOCK"))
+		if err != nil {
+			log.Printf("WARNING: skipping ssh-agent authentication: %v", err)
+		} else {
+			signers = append(signers, agentSigners...)
+			closer = conn
+		}
+	}
+
+	var auths []gossh.AuthMethod
+	// Every signer has to sit behind a single public key method: the client
+	// tries each method name only once.
+	if len(signers) > 0 {
+		auths = append(auths, gossh.PublicKeys(signers...))
+	}
+	if cfg.Password != "" {
+		auths = append(auths, gossh.Password(cfg.Password))
+	}
+	return auths, signers, closer, nil
+}
+
+// authenticate sets up how the hop is authenticated: with its own identity
+// files first, then the target's keys and agent identities in signers, and
+// its own known_hosts file when it names one. The target's password is never
+// offered to a jump host.
+func (h *jumpHost) authenticate(cfg Config, signers []gossh.Signer, hostKeyCallback gossh.HostKeyCallback) error {
+	hopSigners, err := loadKeys(h.KeyPaths, cfg.PassphraseEnv)
+	if err != nil {
+		return fmt.Errorf("jump host %s: %w", h.Host, err)
+	}
+	hopSigners = append(hopSigners, signers...)
+	if len(hopSigners) == 0 {
+		return fmt.Errorf("jump host %s: no SSH key or ssh-agent identity to authenticate with", h.Host)
+	}
+	h.auths = []gossh.AuthMethod{gossh.PublicKeys(hopSigners...)}
+	h.hostKeyCallback = hostKeyCallback
+	if h.KnownHostsPath != "" {
+		if h.hostKeyCallback, err = resolveHostKeyCallback(ExpandHome(h.KnownHostsPath)); err != nil {
+			return fmt.Errorf("jump host %s: %w", h.Host, err)
+		}
+	}
+	return nil
+}
+
+// agentIdentities lists the identities of the ssh-agent listening on socket.
+// The returned connection must stay open while they are used.
+func agentIdentities(socket string) ([]gossh.Signer, io.Closer, error) {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connect to ssh-agent: %w", err)
+	}
+	signers, err := agent.NewClient(conn).Signers()
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("list ssh-agent identities: %w", err)
+	}
+	return signers, conn, nil
+}
+
+// loadKeys loads the signers of every key file in paths, in order.
+func loadKeys(paths []string, passphraseEnv string) ([]gossh.Signer, error) {
+	var signers []gossh.Signer
+	for _, path := range paths {
+		keySigners, err := loadKey(path, passphraseEnv)
+		if err != nil {
+			return nil, err
+		}
+		signers = append(signers, keySigners...)
+	}
+	return signers, nil
+}
+
+// loadKey parses a private key file, decrypting it if needed, and returns its
+// certificate signer (when "<path>-cert.pub" exists) followed by the key signer.
+func loadKey(path, passphraseEnv string) ([]gossh.Signer, error) {
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read private key: %w", err)
+	}
+	signer, err := gossh.ParsePrivateKey(key)
+	var missing *gossh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		var passphrase []byte
+		passphrase, err = readPassphrase(path, passphraseEnv)
+		if err != nil {
+			return nil, err
+		}
+		signer, err = gossh.ParsePrivateKeyWithPassphrase(key, passphrase)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse private key %s: %w", path, err)
+	}
+
+	certBytes, err := os.ReadFile(path + "-cert.pub")
+	if errors.Is(err, os.ErrNotExist) {
+		return []gossh.Signer{signer}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read certificate: %w", err)
+	}
+	pub, _, _, _, err := gossh.ParseAuthorizedKey(certBytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate %s-cert.pub: %w", path, err)
+	}
+	cert, ok := pub.(*gossh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s-cert.pub is not an OpenSSH certificate", path)
+	}
+	certSigner, err := gossh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("certificate %s-cert.pub: %w", path, err)
+	}
+	return []gossh.Signer{certSigner, signer}, nil
+}
+
+// readPassphrase reads the passphrase of an encrypted key from passphraseEnv,
+// or else prompts for it on the terminal.
+func readPassphrase(path, passphraseEnv string) ([]byte, error) {
+	if passphraseEnv != "" {
+		if value, ok := os.LookupEnv(passphraseEnv); ok {
+			return []byte(value), nil
+		}
+	}
+
+	passphrases.Lock()
+	defer passphrases.Unlock()
+	if cached, ok := passphrases.byPath[path]; ok {
+		return cached, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("private key %s is encrypted; set ssh.passphraseEnv or run from a terminal", path)
+	}
+	fmt.Fprintf(os.Stderr, "Enter passphrase for %s: ", path)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("read passphrase: %w", err)
+	}
+	passphrases.byPath[path] = passphrase
+	return passphrase, nil
+}
@@ -0,0 +1,20 @@
+package ssh
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAuthMethodsSkipsUnreachableAgent(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", filepath.Join(t.TempDir(), "agent.sock"))
+	auths, signers, closer, err := authMethods(Config{Password: "secret"})
+	if err != nil {
+		t.Fatalf("authMethods() error = %v", err)
+	}
+	if closer != nil {
+		t.Error("authMethods() returned an agent connection")
+	}
+	if len(auths) != 1 || len(signers) != 0 {
+		t.Errorf("authMethods() = %d methods and %d signers, want only the password", len(auths), len(signers))
+	}
+}
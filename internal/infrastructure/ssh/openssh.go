@@ -0,0 +1,166 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/kevinburke/ssh_config"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// defaultConfigFile is the OpenSSH client configuration consulted by default.
+const defaultConfigFile = "~/.ssh/config"
+
+// jumpHost is one hop of a ProxyJump chain. KeyPaths and KnownHostsPath come
+// from the hop's own IdentityFile and UserKnownHostsFile settings.
+type jumpHost struct {
+	User           string
+	Host           string
+	Port           int
+	KeyPaths       []string
+	KnownHostsPath string
+
+	auths           []gossh.AuthMethod
+	hostKeyCallback gossh.HostKeyCallback
+}
+
+// maxJumpHosts bounds ProxyJump chains, which may nest through the OpenSSH
+// config and would otherwise loop forever on a cycle.
+const maxJumpHosts = 8
+
+// resolve fills the fields left unset in cfg from the OpenSSH client
+// configuration for the cfg.Host alias: HostName, User, Port, IdentityFile,
+// ProxyJump and UserKnownHostsFile. Fields set in cfg always win. Identity
+// files from the OpenSSH config that do not exist are skipped, as ssh does.
+func resolve(cfg Config) (Config, error) {
+	conf, err := loadOpenSSHConfig(cfg.ConfigFile)
+	if err != nil {
+		return cfg, err
+	}
+	if conf != nil {
+		alias := cfg.Host
+		get := func(key string) string {
+			value, _ := conf.Get(alias, key)
+			return value
+		}
+		if hostName := get("HostName"); hostName != "" {
+			cfg.Host = strings.ReplaceAll(hostName, "%h", alias)
+		}
+		if cfg.User == "" {
+			cfg.User = get("User")
+		}
+		if cfg.Port == 0 {
+			if port := get("Port"); port != "" {
+				cfg.Port, err = strconv.Atoi(port)
+				if err != nil {
+					return cfg, fmt.Errorf("ssh config: invalid port %q for %s", port, alias)
+				}
+			}
+		}
+		if cfg.KnownHostsPath == "" {
+			if files := strings.Fields(get("UserKnownHostsFile")); len(files) > 0 {
+				cfg.KnownHostsPath = files[0]
+			}
+		}
+		if cfg.ProxyJump == "" {
+			cfg.ProxyJump = get("ProxyJump")
+		}
+		if cfg.PrivateKeyPath == "" && len(cfg.PrivateKeyPaths) == 0 {
+			identities, _ := conf.GetAll(alias, "IdentityFile")
+			for _, identity := range identities {
+				if _, err := os.Stat(ExpandHome(identity)); err == nil {
+					cfg.PrivateKeyPaths = append(cfg.PrivateKeyPaths, identity)
+				}
+			}
+		}
+	}
+
+	if strings.EqualFold(cfg.ProxyJump, "none") {
+		cfg.ProxyJump = ""
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 22
+	}
+	if cfg.User == "" {
+		if current, err := user.Current(); err == nil {
+			cfg.User = current.Username
+		}
+	}
+	return cfg, nil
+}
+
+// loadOpenSSHConfig parses the OpenSSH client configuration at path, or at
+// ~/.ssh/config when path is empty. A missing file yields nil.
+func loadOpenSSHConfig(path string) (*ssh_config.Config, error) {
+	if path == "" {
+		path = defaultConfigFile
+	}
+	f, err := os.Open(ExpandHome(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	conf, err := ssh_config.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return conf, nil
+}
+
+// jumpHosts parses a ProxyJump value ("[user@]host[:port],...") and resolves
+// each hop through the OpenSSH config. As with ssh, the ProxyJump setting of
+// the first hop is honoured and prepended to the chain.
+func jumpHosts(proxyJump, configFile string) ([]jumpHost, error) {
+	return jumpChain(proxyJump, configFile, 0)
+}
+
+// jumpChain resolves proxyJump, reached through depth nested ProxyJump
+// settings.
+func jumpChain(proxyJump, configFile string, depth int) ([]jumpHost, error) {
+	if depth > maxJumpHosts {
+		return nil, fmt.Errorf("ProxyJump chain is longer than %d hosts; check the OpenSSH config for a loop", maxJumpHosts)
+	}
+	if proxyJump == "" {
+		return nil, nil
+	}
+	var hops []jumpHost
+	for i, spec := range strings.Split(proxyJump, ",") {
+		u, err := url.Parse("ssh://" + strings.TrimSpace(spec))
+		if err != nil || u.Hostname() == "" {
+			return nil, fmt.Errorf("invalid ProxyJump host %q", spec)
+		}
+		hop := Config{Host: u.Hostname(), User: u.User.Username(), ConfigFile: configFile}
+		if u.Port() != "" {
+			if hop.Port, err = strconv.Atoi(u.Port()); err != nil {
+				return nil, fmt.Errorf("invalid ProxyJump port in %q", spec)
+			}
+		}
+		hop, err = resolve(hop)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 && hop.ProxyJump != "" {
+			if hops, err = jumpChain(hop.ProxyJump, configFile, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		hops = append(hops, jumpHost{User: hop.User, Host: hop.Host, Port: hop.Port, KeyPaths: hop.KeyPaths(), KnownHostsPath: hop.KnownHostsPath})
+		if len(hops) > maxJumpHosts {
+			return nil, fmt.Errorf("ProxyJump chain is longer than %d hosts; check the OpenSSH config for a loop", maxJumpHosts)
+		}
+	}
+	return hops, nil
+}
+
+func (h jumpHost) addr() string {
+	return net.JoinHostPort(h.Host, strconv.Itoa(h.Port))
+}
@@ -0,0 +1,72 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJumpHosts(t *testing.T) {
+	dir := t.TempDir()
+	bastionKey := filepath.Join(dir, "bastion_key")
+	if err := os.WriteFile(bastionKey, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, "config")
+	content := strings.NewReplacer("KEY", bastionKey, "DIR", dir).Replace(`
+Host bastion
+  HostName bastion.example.com
+  User ops
+  IdentityFile KEY
+  IdentityFile DIR/missing_key
+  UserKnownHostsFile DIR/bastion_known_hosts
+
+Host inner
+  HostName 10.0.0.5
+  Port 2200
+  ProxyJump bastion
+
+Host loop
+  ProxyJump loop
+`)
+	if err := os.WriteFile(config, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	bastion := jumpHost{User: "ops", Host: "bastion.example.com", Port: 22, KeyPaths: []string{bastionKey}, KnownHostsPath: dir + "/bastion_known_hosts"}
+	tests := []struct {
+		name      string
+		proxyJump string
+		want      []jumpHost
+		wantErr   bool
+	}{
+		{"none", "", nil, false},
+		{"hop settings", "bastion", []jumpHost{bastion}, false},
+		{"explicit user and port", "deploy@bastion:2022", []jumpHost{{User: "deploy", Host: "bastion.example.com", Port: 2022, KeyPaths: []string{bastionKey}, KnownHostsPath: dir + "/bastion_known_hosts"}}, false},
+		{"nested first hop", "inner,jump2.example.com:2201", []jumpHost{bastion, {User: userName(t), Host: "10.0.0.5", Port: 2200}, {User: userName(t), Host: "jump2.example.com", Port: 2201}}, false},
+		{"loop", "loop", nil, true},
+		{"invalid", "bastion:port", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := jumpHosts(tt.proxyJump, config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("jumpHosts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("jumpHosts() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// userName is the user resolve falls back to when none is configured.
+func userName(t *testing.T) string {
+	cfg, err := resolve(Config{Host: "x", ConfigFile: os.DevNull})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg.User
+}
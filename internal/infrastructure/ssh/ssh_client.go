@@ -1,12 +1,19 @@
 package ssh
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/pkg/sftp"
 	gossh "golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/knownhosts"
 )
@@ -18,87 +25,419 @@ type Config struct {
 	Port           int    `yaml:"port"`
 	Password       string `yaml:"password"`
 	PrivateKeyPath string `yaml:"privateKeyPath"`
+	// PrivateKeyPaths are further key files tried after PrivateKeyPath.
+	PrivateKeyPaths []string `yaml:"privateKeyPaths"`
+	// PassphraseEnv names the environment variable holding the passphrase of
+	// encrypted keys; without it the passphrase is prompted for.
+	PassphraseEnv string `yaml:"passphraseEnv"`
+	// Agent enables ssh-agent authentication through SSH_AUTH_SOCK (default true).
+	Agent          *bool  `yaml:"agent"`
 	KnownHostsPath string `yaml:"knownHostsPath"`
+	// ProxyJump lists jump hosts as "[user@]host[:port]", comma separated.
+	ProxyJump string `yaml:"proxyJump"`
+	// ConfigFile is the OpenSSH client config consulted for unset fields
+	// (default ~/.ssh/config).
+	ConfigFile string `yaml:"configFile"`
+	// KeepAlive is the interval between keepalive requests (default 15s);
+	// a negative value disables them.
+	KeepAlive time.Duration `yaml:"keepAlive"`
+	// CommandTimeout bounds every non-streaming remote command; zero means
+	// no limit. Commands exceeding it are sent SIGTERM.
+	CommandTimeout time.Duration `yaml:"commandTimeout"`
 }
 
-// Client wraps an SSH client connection.
+// Client wraps an SSH client connection. A connection that stops answering
+// keepalives is dropped and transparently re-established by the next command.
 type Client struct {
+	cfg             Config
+	hops            []jumpHost
+	auths           []gossh.AuthMethod
+	hostKeyCallback gossh.HostKeyCallback
+	agent           io.Closer
+
+	mu   sync.Mutex
+	conn *connection
+}
+
+// connection is one established SSH connection together with the jump host
+// connections it is tunnelled through.
+type connection struct {
 	client *gossh.Client
+	// jumps are the connections to the jump hosts, outermost first.
+	jumps []*gossh.Client
+	// done is closed once the server side of the connection is gone.
+	done chan struct{}
+
+	// closed is closed as soon as close is called, so that the connection
+	// counts as lost before the server side notices.
+	closed    chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+
+	mu   sync.Mutex
+	sftp *sftp.Client
 }
 
-// New creates and connects an SSH client.
+const (
+	defaultKeepAlive = 15 * time.Second
+	// keepAliveMisses is the number of unanswered keepalives after which the
+	// connection is considered lost.
+	keepAliveMisses = 3
+	// stopGrace is how long a cancelled command may take to exit after
+	// SIGTERM before its session is closed.
+	stopGrace = 10 * time.Second
+)
+
+// ErrConnectionLost reports that the connection dropped while a command ran,
+// so it is unknown whether the command completed.
+var ErrConnectionLost = errors.New("ssh connection lost")
+
+// ErrCommandTimeout reports that a command exceeded Config.CommandTimeout.
+var ErrCommandTimeout = errors.New("remote command timed out")
+
+// New creates and connects an SSH client. cfg.Host may be an alias of the
+// OpenSSH config, whose settings fill the fields left unset in cfg; the
+// connection is tunnelled through the ProxyJump hosts, if any.
 func New(cfg Config) (*Client, error) {
-	auths := []gossh.AuthMethod{}
-	if cfg.PrivateKeyPath != "" {
-		key, err := os.ReadFile(cfg.PrivateKeyPath)
-		if err != nil {
-			return nil, fmt.Errorf("read private key: %w", err)
+	cfg, err := resolve(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.KeepAlive == 0 {
+		cfg.KeepAlive = defaultKeepAlive
+	}
+	hops, err := jumpHosts(cfg.ProxyJump, cfg.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeyCallback, err := resolveHostKeyCallback(ExpandHome(cfg.KnownHostsPath))
+	if err != nil {
+		return nil, err
+	}
+
+	auths, signers, agentConn, err := authMethods(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(auths) == 0 {
+		return nil, fmt.Errorf("no SSH authentication method provided")
+	}
+
+	c := &Client{cfg: cfg, hops: hops, auths: auths, hostKeyCallback: hostKeyCallback, agent: agentConn}
+	for i := range c.hops {
+		if err := c.hops[i].authenticate(cfg, signers, hostKeyCallback); err != nil {
+			c.Close()
+			return nil, err
 		}
-		signer, err := gossh.ParsePrivateKey(key)
+	}
+	if c.conn, err = c.dial(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// dial opens a new connection through the jump hosts and starts its keepalives.
+func (c *Client) dial() (*connection, error) {
+	clientConfig := func(user string, auths []gossh.AuthMethod, hostKeyCallback gossh.HostKeyCallback) *gossh.ClientConfig {
+		return &gossh.ClientConfig{
+			User:            user,
+			Auth:            auths,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         15 * time.Second,
+		}
+	}
+
+	conn := &connection{done: make(chan struct{}), closed: make(chan struct{})}
+	var via *gossh.Client
+	for _, hop := range c.hops {
+		next, err := dial(via, hop.addr(), clientConfig(hop.User, hop.auths, hop.hostKeyCallback))
 		if err != nil {
-			return nil, fmt.Errorf("parse private key: %w", err)
+			conn.close()
+			return nil, fmt.Errorf("dial jump host %s: %w", hop.addr(), err)
 		}
-		auths = append(auths, gossh.PublicKeys(signer))
+		conn.jumps = append(conn.jumps, next)
+		via = next
 	}
-	if cfg.Password != "" {
-		auths = append(auths, gossh.Password(cfg.Password))
+	addr := net.JoinHostPort(c.cfg.Host, strconv.Itoa(c.cfg.Port))
+	cli, err := dial(via, addr, clientConfig(c.cfg.User, c.auths, c.hostKeyCallback))
+	if err != nil {
+		conn.close()
+		return nil, fmt.Errorf("dial ssh: %w", err)
 	}
+	conn.client = cli
 
-	if len(auths) == 0 {
-		return nil, fmt.Errorf("no SSH authentication method provided")
+	go func() {
+		cli.Wait()
+		close(conn.done)
+	}()
+	if c.cfg.KeepAlive > 0 {
+		go conn.keepAlive(c.cfg.KeepAlive)
 	}
+	return conn, nil
+}
 
-	hostKeyCallback, err := resolveHostKeyCallback(cfg.KnownHostsPath)
+// dial connects to addr directly, or through the via connection when set.
+func dial(via *gossh.Client, addr string, config *gossh.ClientConfig) (*gossh.Client, error) {
+	if via == nil {
+		return gossh.Dial("tcp", addr, config)
+	}
+	conn, err := via.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	sshConn, chans, reqs, err := gossh.NewClientConn(conn, addr, config)
 	if err != nil {
+		conn.Close()
 		return nil, err
 	}
+	return gossh.NewClient(sshConn, chans, reqs), nil
+}
 
-	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
-	clientConfig := &gossh.ClientConfig{
-		User:            cfg.User,
-		Auth:            auths,
-		HostKeyCallback: hostKeyCallback,
-		Timeout:         15 * time.Second,
+// keepAlive sends a keepalive request every interval and closes the
+// connection once keepAliveMisses requests in a row went unanswered.
+func (conn *connection) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	misses := 0
+	for {
+		select {
+		case <-conn.done:
+			return
+		case <-ticker.C:
+		}
+		reply := make(chan error, 1)
+		go func() {
+			_, _, err := conn.client.SendRequest("keepalive@openssh.com", true, nil)
+			reply <- err
+		}()
+		select {
+		case <-conn.done:
+			return
+		case err := <-reply:
+			if err != nil {
+				misses = keepAliveMisses
+			} else {
+				misses = 0
+			}
+		case <-time.After(interval):
+			misses++
+		}
+		if misses >= keepAliveMisses {
+			log.Printf("WARNING: ssh connection to %s stopped answering keepalives; closing it", conn.client.RemoteAddr())
+			conn.close()
+			return
+		}
 	}
+}
+
+// lost reports whether the connection is gone or being closed.
+func (conn *connection) lost() bool {
+	select {
+	case <-conn.done:
+		return true
+	case <-conn.closed:
+		return true
+	default:
+		return false
+	}
+}
 
-	cli, err := gossh.Dial("tcp", addr, clientConfig)
+// close closes the connection once; the keepalive, command and session
+// goroutines may all call it.
+func (conn *connection) close() error {
+	conn.closeOnce.Do(func() {
+		close(conn.closed)
+		conn.mu.Lock()
+		sftpClient := conn.sftp
+		conn.mu.Unlock()
+		if sftpClient != nil {
+			sftpClient.Close()
+		}
+		if conn.client != nil {
+			conn.closeErr = conn.client.Close()
+		}
+		for i := len(conn.jumps) - 1; i >= 0; i-- {
+			conn.jumps[i].Close()
+		}
+	})
+	return conn.closeErr
+}
+
+// current returns the live connection, reconnecting when the previous one
+// was lost.
+func (c *Client) current() (*connection, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil, fmt.Errorf("ssh client is closed")
+	}
+	if !c.conn.lost() {
+		return c.conn, nil
+	}
+	log.Printf("ssh connection to %s lost; reconnecting", c.cfg.Host)
+	c.conn.close()
+	conn, err := c.dial()
 	if err != nil {
-		return nil, fmt.Errorf("dial ssh: %w", err)
+		return nil, fmt.Errorf("reconnect: %w", err)
 	}
-	return &Client{client: cli}, nil
+	c.conn = conn
+	return conn, nil
 }
 
-// Run executes a command and returns the combined output.
-func (c *Client) Run(command string) (string, error) {
-	session, err := c.client.NewSession()
+// session opens a session on the live connection. A session that cannot be
+// opened because the connection just dropped is retried once on a new
+// connection; no command has started at that point.
+func (c *Client) session() (*gossh.Session, *connection, error) {
+	conn, err := c.current()
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
-	defer session.Close()
+	session, err := conn.client.NewSession()
+	if err != nil && connectionError(conn, err) {
+		conn.close()
+		if conn, err = c.current(); err != nil {
+			return nil, nil, err
+		}
+		session, err = conn.client.NewSession()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return session, conn, nil
+}
+
+// connectionError reports whether err stems from the connection going away
+// rather than from the remote command.
+func connectionError(conn *connection, err error) bool {
+	var exit *gossh.ExitError
+	if errors.As(err, &exit) {
+		return false
+	}
+	var missing *gossh.ExitMissingError
+	return errors.Is(err, io.EOF) || errors.As(err, &missing) || conn.lost()
+}
+
+// Run executes a command writing its stdout and stderr to the given writers.
+// The command is stopped when ctx is done or Config.CommandTimeout elapses.
+func (c *Client) Run(ctx context.Context, command string, stdout, stderr io.Writer) error {
+	if c.cfg.CommandTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, c.cfg.CommandTimeout, fmt.Errorf("%w after %s", ErrCommandTimeout, c.cfg.CommandTimeout))
+		defer cancel()
+	}
+	return c.run(ctx, command, stdout, stderr)
+}
+
+// RunStream executes a command streaming stdout and stderr to writer until it
+// exits or ctx is done. CommandTimeout does not apply, so "tail -f" can run
+// unbounded.
+func (c *Client) RunStream(ctx context.Context, command string, writer io.Writer) error {
+	return c.run(ctx, command, writer, writer)
+}
 
-	output, err := session.CombinedOutput(command)
-	return string(output), err
+// ExitStatus returns the exit status of a command that ran to completion and
+// failed, as reported by Run and RunStream.
+func ExitStatus(err error) (int, bool) {
+	var exit *gossh.ExitError
+	if errors.As(err, &exit) {
+		return exit.ExitStatus(), true
+	}
+	return 0, false
 }
 
-// RunStream executes a command streaming stdout to writer.
-func (c *Client) RunStream(command string, writer io.Writer) error {
-	session, err := c.client.NewSession()
+// run executes command writing its output to stdout and stderr. When ctx is
+// done the remote process is sent SIGTERM and, if it has not exited after
+// stopGrace, its session is closed.
+func (c *Client) run(ctx context.Context, command string, stdout, stderr io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return context.Cause(ctx)
+	}
+	session, conn, err := c.session()
 	if err != nil {
 		return err
 	}
 	defer session.Close()
 
-	session.Stdout = writer
-	session.Stderr = writer
-	return session.Run(command)
+	session.Stdout = stdout
+	session.Stderr = stderr
+	if err := session.Start(command); err != nil {
+		return err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- session.Wait() }()
+
+	select {
+	case err := <-exited:
+		if err != nil && connectionError(conn, err) {
+			conn.close()
+			return fmt.Errorf("%w: %v", ErrConnectionLost, err)
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	session.Signal(gossh.SIGTERM)
+	select {
+	case <-exited:
+	case <-time.After(stopGrace):
+		session.Close()
+	}
+	return fmt.Errorf("remote command stopped: %w", context.Cause(ctx))
 }
 
-// Close terminates the SSH connection.
+// SFTP returns the SFTP session of the live connection, opening it on first
+// use and again after a reconnect.
+func (c *Client) SFTP() (*sftp.Client, error) {
+	conn, err := c.current()
+	if err != nil {
+		return nil, err
+	}
+	client, err := conn.sftpClient()
+	if errors.Is(err, ErrConnectionLost) {
+		// The connection was closed in between; no operation has started.
+		if conn, err = c.current(); err != nil {
+			return nil, err
+		}
+		client, err = conn.sftpClient()
+	}
+	return client, err
+}
+
+// sftpClient returns the SFTP session of conn, opening it on first use. A
+// closed connection reports ErrConnectionLost, so no session is opened that
+// its close would miss.
+func (conn *connection) sftpClient() (*sftp.Client, error) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.lost() {
+		return nil, ErrConnectionLost
+	}
+	if conn.sftp != nil {
+		return conn.sftp, nil
+	}
+	client, err := sftp.NewClient(conn.client)
+	if err != nil {
+		return nil, fmt.Errorf("open sftp session: %w", err)
+	}
+	conn.sftp = client
+	return client, nil
+}
+
+// Close terminates the SSH connection and the jump host connections.
 func (c *Client) Close() error {
-	if c.client == nil {
-		return nil
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	if c.conn != nil {
+		err = c.conn.close()
+		c.conn = nil
+	}
+	if c.agent != nil {
+		c.agent.Close()
 	}
-	return c.client.Close()
+	return err
 }
 
 func resolveHostKeyCallback(path string) (gossh.HostKeyCallback, error) {
@@ -0,0 +1,29 @@
+package ssh
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestConnectionCloseMarksLost(t *testing.T) {
+	conn := &connection{done: make(chan struct{}), closed: make(chan struct{})}
+	if conn.lost() {
+		t.Fatal("new connection reported lost")
+	}
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn.close()
+		}()
+	}
+	wg.Wait()
+	if !conn.lost() {
+		t.Error("closed connection is not reported lost")
+	}
+	if _, err := conn.sftpClient(); !errors.Is(err, ErrConnectionLost) {
+		t.Errorf("sftpClient() on a closed connection = %v, want ErrConnectionLost", err)
+	}
+}
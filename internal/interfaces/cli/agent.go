@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+)
+
+// handleAgent runs server-side subcommands. "agent run" deploys the project
+// on the machine it runs on; the post-receive hook installed by init calls it
+// so its output streams back to the git push client.
+func (c CLI) handleAgent(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "run" {
+		c.usage()
+		return fmt.Errorf("unknown agent command")
+	}
+	if c.Agent == nil {
+		return fmt.Errorf("agent mode is not available")
+	}
+
+	fs := flag.NewFlagSet("agent run", flag.ExitOnError)
+	branch := fs.String("branch", "", "branch that was pushed")
+	commit := fs.String("commit", "", "commit to deploy")
+	fs.Parse(args[1:])
+	if fs.NArg() < 1 {
+		return fmt.Errorf("project name required")
+	}
+	project, err := c.resolveProject(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *branch != "" {
+		project.Branch = *branch
+	}
+	project.Commit = *commit
+
+	session, err := c.Agent()
+	if err != nil {
+		return err
+	}
+	defer c.close(session)
+
+	c.Logger.Info("deploying %s (%s)", project.Name, project.Revision())
+	result, err := session.Deploy.Deploy(ctx, project)
+	c.logHooks(c.Logger, result.Hooks)
+	if err != nil {
+		c.Logger.Error(result.Message)
+		c.logCommandFailure(c.Logger, err)
+		return err
+	}
+	c.Logger.Info(result.Message)
+	return nil
+}
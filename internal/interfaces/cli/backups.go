@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"github.com/dadyutenga/git-engine/internal/infrastructure/logger"
+)
+
+// handleBackups runs the "backups list|show|delete|prune|pull|push"
+// subcommands.
+func (c CLI) handleBackups(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		c.usage()
+		return fmt.Errorf("backups command required")
+	}
+	sub := args[0]
+	fs := flag.NewFlagSet("backups "+sub, flag.ExitOnError)
+	tf := addTargetFlags(fs)
+	dir := fs.String("dir", ".", "local directory pulled backups are written to")
+	fs.Parse(args[1:])
+
+	operands := 1
+	if sub == "show" || sub == "delete" || sub == "push" {
+		operands = 2
+	}
+	switch {
+	case !slices.Contains([]string{"list", "show", "delete", "prune", "pull", "push"}, sub):
+		c.usage()
+		return fmt.Errorf("unknown backups command: %s", sub)
+	case fs.NArg() < 1:
+		return fmt.Errorf("project name required")
+	case fs.NArg() < operands && sub == "push":
+		return fmt.Errorf("backup file required")
+	case fs.NArg() < operands:
+		return fmt.Errorf("backup name required")
+	}
+	project, err := c.resolveProject(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	name := fs.Arg(1)
+
+	return c.forEachTarget(tf, func(_ Target, log logger.Logger, session Session) error {
+		defer c.printPlan(log, session)
+		switch sub {
+		case "list":
+			backups, err := session.Backups.List(ctx, project)
+			if err != nil {
+				return err
+			}
+			log.Info("%d backups of %s in %s", len(backups), project.Name, project.BackupDir)
+			return printBackups(backups)
+		case "show":
+			b, err := session.Backups.Show(ctx, project, name)
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintf(w, "name:\t%s\n", b.Name)
+			fmt.Fprintf(w, "path:\t%s/%s\n", project.BackupDir, b.Name)
+			fmt.Fprintf(w, "size:\t%s (%d bytes)\n", formatSize(b.Size), b.Size)
+			fmt.Fprintf(w, "created:\t%s (%s ago)\n", b.CreatedAt.Local().Format(time.DateTime), formatAge(time.Since(b.CreatedAt)))
+			fmt.Fprintf(w, "release:\t%s\n", orDash(b.Release))
+			fmt.Fprintf(w, "commit:\t%s\n", orDash(b.Commit))
+			fmt.Fprintf(w, "strategy:\t%s\n", orDash(b.Strategy))
+			fmt.Fprintf(w, "compression:\t%s\n", orDash(b.Compression))
+			fmt.Fprintf(w, "excludes:\t%s\n", orDash(strings.Join(b.Excludes, " ")))
+			if b.Database != nil {
+				fmt.Fprintf(w, "database:\t%s dump %s (%s)\n", b.Database.Engine, b.Database.File, formatSize(b.Database.Size))
+			} else {
+				fmt.Fprintf(w, "database:\t-\n")
+			}
+			if b.HasManifest {
+				fmt.Fprintf(w, "sha256:\t%s\n", orDash(b.SHA256))
+			} else {
+				fmt.Fprintf(w, "sha256:\tunknown (no manifest)\n")
+			}
+			return w.Flush()
+		case "delete":
+			if err := session.Backups.Delete(ctx, project, name); err != nil {
+				return err
+			}
+			log.Info("deleted backup %s", name)
+			return nil
+		case "pull":
+			b, path, err := session.Backups.Pull(ctx, project, name, *dir)
+			if err != nil {
+				return err
+			}
+			log.Info("pulled backup %s (%s, sha256 %s) to %s", b.Name, formatSize(b.Size), b.SHA256, path)
+			return nil
+		case "push":
+			b, err := session.Backups.Push(ctx, project, name)
+			if err != nil {
+				return err
+			}
+			log.Info("pushed %s as backup %s", name, b.Name)
+			return nil
+		default:
+			pruned, err := session.Backups.Prune(ctx, project)
+			for _, b := range pruned {
+				log.Info("deleted backup %s (%s, %s old)", b.Name, formatSize(b.Size), formatAge(time.Since(b.CreatedAt)))
+			}
+			if err != nil {
+				return err
+			}
+			log.Info("%d backups pruned", len(pruned))
+			return nil
+		}
+	})
+}
+
+// printBackups lists backups as a table.
+func printBackups(backups []domain.Backup) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSIZE\tAGE\tRELEASE\tCOMMIT")
+	for _, b := range backups {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", b.Name, formatSize(b.Size), formatAge(time.Since(b.CreatedAt)), orDash(b.Release), orDash(fmt.Sprintf("%.7s", b.Commit)))
+	}
+	return w.Flush()
+}
+
+// formatSize renders a byte count with a binary unit.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatAge renders a duration in the largest whole unit, such as 3d or 5h.
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	default:
+		return fmt.Sprintf("%ds", int(d/time.Second))
+	}
+}
@@ -1,26 +1,50 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/dadyutenga/git-engine/internal/application"
+	"github.com/dadyutenga/git-engine/internal/domain"
 	"github.com/dadyutenga/git-engine/internal/infrastructure/logger"
+	"github.com/dadyutenga/git-engine/internal/infrastructure/manifest"
 )
 
 // CLI wires command flags to application services.
 type CLI struct {
-	InitService     application.InitService
-	DeployService   application.DeployService
-	RollbackService application.RollbackService
-	StatusService   application.StatusService
-	LogsService     application.LogsService
-	Logger          logger.Logger
+	Connect Connector
+	// Agent opens the local session used by "deploy agent run".
+	Agent  AgentConnector
+	Logger logger.Logger
+	Config Config
+	// ManifestPath is the deploy.yaml of the repository being deployed.
+	ManifestPath string
 }
 
-// Run parses args and dispatches to the correct service.
-func (c CLI) Run(args []string) error {
+// targetFlags are the host selection flags shared by every command.
+type targetFlags struct {
+	env    *string
+	hosts  *string
+	dryRun *bool
+}
+
+func addTargetFlags(fs *flag.FlagSet) targetFlags {
+	return targetFlags{
+		env:    fs.String("env", "", "environment to target (defaults to defaultEnvironment)"),
+		hosts:  fs.String("host", "", "comma separated hosts of the environment to target"),
+		dryRun: fs.Bool("dry-run", false, "print the remote commands without executing them"),
+	}
+}
+
+// Run parses args and dispatches to the correct service. Cancelling ctx stops
+// the running remote commands.
+func (c CLI) Run(ctx context.Context, args []string) error {
 	if len(args) == 0 {
 		c.usage()
 		return fmt.Errorf("no command supplied")
@@ -28,113 +52,440 @@ func (c CLI) Run(args []string) error {
 
 	switch args[0] {
 	case "init":
-		return c.handleInit(args[1:])
+		return c.handleInit(ctx, args[1:])
 	case "push":
-		return c.handleDeploy(args[1:])
+		return c.handleDeploy(ctx, args[1:])
 	case "rollback":
-		return c.handleRollback(args[1:])
+		return c.handleRollback(ctx, args[1:])
 	case "status":
-		return c.handleStatus(args[1:])
+		return c.handleStatus(ctx, args[1:])
 	case "logs":
-		return c.handleLogs(args[1:])
+		return c.handleLogs(ctx, args[1:])
+	case "history":
+		return c.handleHistory(ctx, args[1:])
+	case "backups":
+		return c.handleBackups(ctx, args[1:])
+	case "agent":
+		return c.handleAgent(ctx, args[1:])
 	default:
 		c.usage()
 		return fmt.Errorf("unknown command: %s", args[0])
 	}
 }
 
-func (c CLI) handleInit(args []string) error {
+func (c CLI) handleInit(ctx context.Context, args []string) error {
 	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	tf := addTargetFlags(fs)
 	fs.Parse(args)
 	if fs.NArg() < 1 {
 		return fmt.Errorf("project name required")
 	}
-	project := fs.Arg(0)
-	result, err := c.InitService.Init(project)
+	project, err := c.resolveProject(fs.Arg(0))
 	if err != nil {
 		return err
 	}
-	c.Logger.Info(result.Message)
-	return nil
+	return c.forEachTarget(tf, func(_ Target, log logger.Logger, session Session) error {
+		result, err := session.Init.Init(ctx, project)
+		c.printPlan(log, session)
+		if err != nil {
+			return stepError(result.Message, err)
+		}
+		log.Info("%s (deploy ID %s)", result.Message, result.ID)
+		return nil
+	})
 }
 
-func (c CLI) handleDeploy(args []string) error {
+func (c CLI) handleDeploy(ctx context.Context, args []string) error {
 	fs := flag.NewFlagSet("push", flag.ExitOnError)
+	tf := addTargetFlags(fs)
+	batch := fs.String("batch", "", "hosts per batch, as a count or a percentage such as 25%")
+	pause := fs.Duration("pause", 0, "pause between batches")
+	maxFailures := fs.Int("max-failures", 0, "failed hosts tolerated before the rollout halts")
+	rollbackOnHalt := fs.Bool("rollback-on-halt", true, "roll updated hosts back when the rollout halts")
+	branch := fs.String("branch", "", "deploy the tip of this branch instead of the configured one")
+	ref := fs.String("ref", "", "deploy a tag or other ref")
+	commit := fs.String("commit", "", "deploy an exact commit SHA")
+	noPush := fs.Bool("no-push", false, "deploy what the server repository already holds without pushing")
 	fs.Parse(args)
 	if fs.NArg() < 1 {
 		return fmt.Errorf("project name required")
 	}
-	project := fs.Arg(0)
-	result, err := c.DeployService.Deploy(project)
+	selectors := 0
+	for _, v := range []string{*branch, *ref, *commit} {
+		if v != "" {
+			selectors++
+		}
+	}
+	if selectors > 1 {
+		return fmt.Errorf("-branch, -ref and -commit are mutually exclusive")
+	}
+	project, err := c.resolveProject(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *branch != "" {
+		project.Branch = *branch
+	}
+	project.Ref = *ref
+	project.Commit = *commit
+	targets, err := c.Config.Targets(*tf.env, *tf.hosts)
+	if err != nil {
+		return err
+	}
+	policy, err := c.Config.Rollout(*tf.env)
 	if err != nil {
 		return err
 	}
-	if !result.Success {
-		return fmt.Errorf(result.Message)
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "batch":
+			policy.BatchSize, policy.BatchPercent, flagErr = parseBatch(*batch)
+		case "pause":
+			policy.Pause = *pause
+		case "max-failures":
+			policy.MaxFailures = *maxFailures
+		case "rollback-on-halt":
+			policy.RollbackOnHalt = *rollbackOnHalt
+		}
+	})
+	if flagErr != nil {
+		return flagErr
+	}
+
+	connector := newSessionConnector(c.Connect, targets, ConnectOptions{DryRun: *tf.dryRun, SkipPush: *noPush})
+	hosts := make([]string, 0, len(targets))
+	for _, t := range targets {
+		hosts = append(hosts, t.Name)
+	}
+	summary := application.FleetDeployService{Connector: connector, Policy: policy}.Deploy(ctx, project, hosts)
+
+	for _, host := range summary.Hosts {
+		log := c.Logger.Sub(host.Host)
+		result := host.Result
+		c.logHooks(log, result.Hooks)
+		if session, ok := connector.session(host.Host); ok {
+			c.printPlan(log, session)
+		}
+		switch {
+		case host.Err != nil:
+			log.Error("%s: %v", result.Message, host.Err)
+			c.logCommandFailure(log, host.Err)
+		case result.Status == "skipped" || result.Status == "rolled_back":
+			log.Info(result.Message)
+		case !result.Success:
+			log.Error(result.Message)
+		case *tf.dryRun:
+			log.Info("dry run: %s would deploy %s (%s) as release %s with the %s strategy", project.Name, result.Details["ref"], result.Details["commit"], result.Details["release"], result.Details["strategy"])
+		default:
+			log.Info(result.Message)
+		}
+		if result.ID != "" && !*tf.dryRun {
+			log.Info("deploy ID %s", result.ID)
+		}
+	}
+
+	if len(summary.Hosts) > 1 {
+		c.Logger.Info("%s: %d succeeded, %d failed, %d rolled back, %d skipped", project.Name, summary.Succeeded, summary.Failed, summary.RolledBack, summary.Skipped)
+		if summary.Halted {
+			c.Logger.Error("rollout of %s halted after exceeding %d tolerated failures", project.Name, policy.MaxFailures)
+		}
+	}
+	if !summary.Success() {
+		return fmt.Errorf("deployment of %s did not complete on %d of %d hosts", project.Name, len(summary.Hosts)-summary.Succeeded, len(summary.Hosts))
 	}
-	c.Logger.Info(result.Message)
 	return nil
 }
 
-func (c CLI) handleRollback(args []string) error {
+func (c CLI) handleRollback(ctx context.Context, args []string) error {
 	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	tf := addTargetFlags(fs)
+	release := fs.String("release", "", "release to activate (defaults to the previous release)")
 	backup := fs.String("backup", "", "backup filename to restore")
+	withDB := fs.Bool("with-db", false, "also restore the database dump taken with the backup")
 	fs.Parse(args)
 	if fs.NArg() < 1 {
 		return fmt.Errorf("project name required")
 	}
-	project := fs.Arg(0)
-	result, err := c.RollbackService.Rollback(project, *backup)
+	if *release != "" && *backup != "" {
+		return fmt.Errorf("-release and -backup are mutually exclusive")
+	}
+	project, err := c.resolveProject(fs.Arg(0))
 	if err != nil {
 		return err
 	}
-	c.Logger.Info(result.Message)
-	return nil
+	return c.forEachTarget(tf, func(_ Target, log logger.Logger, session Session) error {
+		result, err := session.Rollback.Rollback(ctx, project, *release, *backup, *withDB)
+		c.logHooks(log, result.Hooks)
+		c.printPlan(log, session)
+		if err != nil {
+			return stepError(result.Message, err)
+		}
+		log.Info("%s (deploy ID %s)", result.Message, result.ID)
+		if result.Previous != "" {
+			log.Info("replaced release %s%s", result.Previous, orEmpty(" at commit %.7s", result.PreviousCommit))
+		}
+		return nil
+	})
+}
+
+// orEmpty formats value with format, or returns "" when value is empty.
+func orEmpty(format, value string) string {
+	if value == "" {
+		return ""
+	}
+	return fmt.Sprintf(format, value)
 }
 
-func (c CLI) handleStatus(args []string) error {
+func (c CLI) handleStatus(ctx context.Context, args []string) error {
 	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	tf := addTargetFlags(fs)
 	fs.Parse(args)
 	if fs.NArg() < 1 {
 		return fmt.Errorf("project name required")
 	}
-	project := fs.Arg(0)
-	result, err := c.StatusService.Status(project)
+	project, err := c.resolveProject(fs.Arg(0))
 	if err != nil {
 		return err
 	}
-	state := "stopped"
-	if result.Running {
-		state = "running"
-	}
-	c.Logger.Info("project=%s exists=%t strategy=%s state=%s", result.ProjectName, result.Exists, result.Strategy, state)
-	return nil
+	return c.forEachTarget(tf, func(_ Target, log logger.Logger, session Session) error {
+		result, err := session.Status.Status(ctx, project)
+		c.printPlan(log, session)
+		if err != nil {
+			return err
+		}
+		state := "stopped"
+		if result.Running {
+			state = "running"
+		}
+		log.Info("project=%s exists=%t release=%s strategy=%s state=%s", result.ProjectName, result.Exists, result.Release, result.Strategy, state)
+		return nil
+	})
 }
 
-func (c CLI) handleLogs(args []string) error {
+func (c CLI) handleLogs(ctx context.Context, args []string) error {
 	fs := flag.NewFlagSet("logs", flag.ExitOnError)
+	tf := addTargetFlags(fs)
 	follow := fs.Bool("f", false, "follow log output")
 	lines := fs.Int("n", 100, "number of lines")
+	deployID := fs.String("deploy", "", "show only the transcript of this deploy ID")
 	fs.Parse(args)
 	if fs.NArg() < 1 {
 		return fmt.Errorf("project name required")
 	}
-	project := fs.Arg(0)
+	project, err := c.resolveProject(fs.Arg(0))
+	if err != nil {
+		return err
+	}
 	if *lines <= 0 {
 		*lines = 100
 	}
-	return c.LogsService.Tail(project, *lines, *follow, os.Stdout)
+	targets, err := c.Config.Targets(*tf.env, *tf.hosts)
+	if err != nil {
+		return err
+	}
+
+	// Logs of several hosts are streamed concurrently with a host prefix per line.
+	var mu sync.Mutex
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target Target) {
+			defer wg.Done()
+			session, err := c.Connect(target, ConnectOptions{DryRun: *tf.dryRun})
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", target.Name, err)
+				return
+			}
+			defer c.close(session)
+
+			writer := &prefixWriter{mu: &mu, dst: os.Stdout}
+			if len(targets) > 1 {
+				writer.prefix = []byte("[" + target.Name + "] ")
+			}
+			// Interrupting "logs -f" is the normal way to stop following.
+			opts := application.TailOptions{Lines: *lines, Follow: *follow, DeployID: *deployID}
+			if err := session.Logs.Tail(ctx, project, opts, writer); err != nil && ctx.Err() == nil {
+				errs[i] = fmt.Errorf("%s: %w", target.Name, err)
+			}
+			c.printPlan(c.Logger.Sub(target.Name), session)
+		}(i, target)
+	}
+	wg.Wait()
+	return joinHostErrors(errs, len(targets))
+}
+
+// forEachTarget opens a session per selected host and runs fn sequentially,
+// continuing past failing hosts and reporting them together.
+func (c CLI) forEachTarget(tf targetFlags, fn func(target Target, log logger.Logger, session Session) error) error {
+	targets, err := c.Config.Targets(*tf.env, *tf.hosts)
+	if err != nil {
+		return err
+	}
+	errs := make([]error, len(targets))
+	for i, target := range targets {
+		log := c.Logger
+		if len(targets) > 1 {
+			log = c.Logger.Sub(target.Name)
+		}
+		session, err := c.Connect(target, ConnectOptions{DryRun: *tf.dryRun})
+		if err != nil {
+			errs[i] = fmt.Errorf("%s: %w", target.Name, err)
+			log.Error("%v", err)
+			continue
+		}
+		if err := fn(target, log, session); err != nil {
+			c.logCommandFailure(log, err)
+			errs[i] = fmt.Errorf("%s: %w", target.Name, err)
+			if len(targets) > 1 {
+				log.Error("%v", err)
+			}
+		}
+		c.close(session)
+	}
+	return joinHostErrors(errs, len(targets))
+}
+
+// joinHostErrors returns the single error of a one-host run unchanged, and a
+// count of failing hosts otherwise.
+func joinHostErrors(errs []error, total int) error {
+	failed := 0
+	var last error
+	for _, err := range errs {
+		if err != nil {
+			failed++
+			last = err
+		}
+	}
+	switch {
+	case failed == 0:
+		return nil
+	case total == 1:
+		return last
+	default:
+		return fmt.Errorf("%d of %d hosts failed", failed, total)
+	}
+}
+
+func (c CLI) close(session Session) {
+	if session.Close == nil {
+		return
+	}
+	if err := session.Close(); err != nil {
+		c.Logger.Error("failed to close session: %v", err)
+	}
+}
+
+// stderrTailLines is the number of stderr lines shown for a failed command.
+const stderrTailLines = 10
+
+// logCommandFailure shows the command behind a *domain.RemoteCommandError in
+// err together with the last lines it wrote to stderr.
+func (c CLI) logCommandFailure(log logger.Logger, err error) {
+	var cmdErr *domain.RemoteCommandError
+	if !errors.As(err, &cmdErr) {
+		return
+	}
+	log.Error("failed command (exit status %d, after %s): %s", cmdErr.Result.ExitStatus, cmdErr.Result.Duration.Round(time.Millisecond), commandSummary(cmdErr.Result.Command))
+	for _, line := range cmdErr.Result.StderrTail(stderrTailLines) {
+		log.Error("  | %s", line)
+	}
+}
+
+// commandSummary shortens a command to its first line for display.
+func commandSummary(command string) string {
+	const limit = 160
+	line, _, multiline := strings.Cut(strings.TrimSpace(command), "\n")
+	if len(line) > limit {
+		line, multiline = line[:limit], true
+	}
+	if multiline {
+		line += " ..."
+	}
+	return line
+}
+
+// stepError prefixes err with the description of the step that failed.
+func stepError(step string, err error) error {
+	if step == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", step, err)
+}
+
+// printPlan lists the steps recorded during a dry run.
+func (c CLI) printPlan(log logger.Logger, session Session) {
+	if session.Plan == nil {
+		return
+	}
+	steps := session.Plan.Steps()
+	log.Info("dry run: %d planned steps", len(steps))
+	for i, step := range steps {
+		fmt.Fprintf(os.Stdout, "%3d. %s\n", i+1, step)
+	}
+}
+
+// logHooks prints the captured output of every hook that ran.
+func (c CLI) logHooks(log logger.Logger, hooks []domain.HookResult) {
+	for _, hook := range hooks {
+		where := "remote"
+		if hook.Local {
+			where = "local"
+		}
+		if hook.Success {
+			log.Info("%s hook (%s) %q succeeded", hook.Stage, where, hook.Command)
+		} else {
+			log.Error("%s hook (%s) %q failed: %s", hook.Stage, where, hook.Command, hook.Error)
+		}
+		if hook.Output != "" {
+			log.Info("%s", hook.Output)
+		}
+	}
+}
+
+// resolveProject merges the repository manifest with the CLI config overrides.
+// A manifest naming a different project is ignored.
+func (c CLI) resolveProject(name string) (domain.Project, error) {
+	settings := manifest.Manifest{}
+	if c.ManifestPath != "" {
+		repo, err := manifest.Load(c.ManifestPath)
+		if err != nil {
+			return domain.Project{}, err
+		}
+		if repo.Name == "" || repo.Name == name {
+			settings = repo
+		}
+	}
+	settings = settings.Merge(c.Config.Projects[name])
+	return settings.Project(name), nil
 }
 
 func (c CLI) usage() {
 	msg := `deploy CLI
 
 Usage:
-  deploy init <project>
-  deploy push <project>
-  deploy rollback [-backup filename] <project>
-  deploy status <project>
-  deploy logs [-f] [-n 100] <project>
+  deploy init [flags] <project>
+  deploy push [flags] [-branch name | -ref tag | -commit sha] [-no-push]
+              [-batch n|n%] [-pause 30s] [-max-failures n] [-rollback-on-halt=false] <project>
+  deploy rollback [flags] [-release id | -backup filename] [-with-db] <project>
+  deploy status [flags] <project>
+  deploy logs [flags] [-f] [-n 100] [-deploy <id>] <project>
+  deploy history [flags] [-n 20] [-operation push|rollback] [-outcome success|failed|rolled_back]
+                 [-user name] [-since 72h|2006-01-02] [-json] <project>
+  deploy backups list|prune [flags] <project>
+  deploy backups show|delete [flags] <project> <backup>
+  deploy backups pull [flags] [-dir path] <project> [backup]
+  deploy backups push [flags] <project> <file>
+  deploy agent run [-branch name] [-commit sha] <project>   (on the server, from the post-receive hook)
+
+Flags accepted by every command:
+  -env name        environment to target
+  -host a,b        restrict the command to some hosts of the environment
+  -dry-run         print the remote commands without executing them
+
+Backups hold the release directory only. The shared directory of a project
+(uploads, .env, storage) is not backed up; back it up separately.
 `
 	_, _ = fmt.Fprintln(os.Stderr, msg)
 }
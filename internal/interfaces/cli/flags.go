@@ -1,15 +1,63 @@
 package cli
 
 import (
+	"fmt"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"github.com/dadyutenga/git-engine/internal/infrastructure/manifest"
 	"github.com/dadyutenga/git-engine/internal/infrastructure/ssh"
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the CLI configuration.
 type Config struct {
+	// SSH is the single target when no environments are declared, and the
+	// defaults inherited by every environment host otherwise.
 	SSH ssh.Config `yaml:"ssh"`
+	// Environments maps names such as staging or production to their hosts.
+	Environments map[string]Environment `yaml:"environments"`
+	// DefaultEnvironment is used when -env is not given.
+	DefaultEnvironment string `yaml:"defaultEnvironment"`
+	// Projects overrides the deploy.yaml manifest of each project.
+	Projects map[string]manifest.Manifest `yaml:"projects"`
+	// AgentPath is the deploy binary on the servers that the post-receive
+	// hook installed by init runs (default "deploy" from the PATH).
+	AgentPath string `yaml:"agentPath"`
+}
+
+// Environment is a named group of hosts.
+type Environment struct {
+	Hosts   []HostConfig  `yaml:"hosts"`
+	Rollout RolloutConfig `yaml:"rollout"`
+}
+
+// RolloutConfig controls how push progresses across the hosts of an environment.
+type RolloutConfig struct {
+	// Batch is a host count ("2") or a percentage of the hosts ("25%").
+	Batch string `yaml:"batch"`
+	// Pause is the delay between batches.
+	Pause time.Duration `yaml:"pause"`
+	// MaxFailures is the number of failed hosts tolerated before the rollout halts.
+	MaxFailures int `yaml:"maxFailures"`
+	// RollbackOnHalt rolls updated hosts back when the rollout halts (default true).
+	RollbackOnHalt *bool `yaml:"rollbackOnHalt"`
+}
+
+// HostConfig describes one host; unset SSH fields fall back to Config.SSH.
+type HostConfig struct {
+	Name       string `yaml:"name"`
+	ssh.Config `yaml:",inline"`
+}
+
+// Target is a resolved host to run a command against.
+type Target struct {
+	Name string
+	SSH  ssh.Config
 }
 
 // LoadConfig reads YAML configuration from path.
@@ -22,8 +70,173 @@ func LoadConfig(path string) (Config, error) {
 	if err := yaml.Unmarshal(content, &cfg); err != nil {
 		return cfg, err
 	}
-	if cfg.SSH.Port == 0 {
-		cfg.SSH.Port = 22
+	for name, env := range cfg.Environments {
+		if len(env.Hosts) == 0 {
+			return cfg, fmt.Errorf("environments.%s: at least one host is required", name)
+		}
+		for i, host := range env.Hosts {
+			if host.Host == "" {
+				return cfg, fmt.Errorf("environments.%s.hosts[%d]: host is required", name, i)
+			}
+		}
+		if _, _, err := parseBatch(env.Rollout.Batch); err != nil {
+			return cfg, fmt.Errorf("environments.%s.rollout: %w", name, err)
+		}
+	}
+	if cfg.DefaultEnvironment != "" {
+		if _, ok := cfg.Environments[cfg.DefaultEnvironment]; !ok {
+			return cfg, fmt.Errorf("defaultEnvironment %q is not declared", cfg.DefaultEnvironment)
+		}
+	}
+	for name, project := range cfg.Projects {
+		if err := project.Validate(); err != nil {
+			return cfg, fmt.Errorf("projects.%s: %w", name, err)
+		}
 	}
 	return cfg, nil
 }
+
+// Targets resolves the hosts selected by an environment name and an optional
+// comma separated host filter matching host names or addresses.
+func (c Config) Targets(env, hosts string) ([]Target, error) {
+	var targets []Target
+	if len(c.Environments) == 0 {
+		if env != "" {
+			return nil, fmt.Errorf("environment %q is not declared", env)
+		}
+		targets = []Target{{Name: c.SSH.Host, SSH: c.SSH}}
+	} else {
+		environment, err := c.environment(env)
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range environment.Hosts {
+			name := host.Name
+			if name == "" {
+				name = host.Host
+			}
+			targets = append(targets, Target{Name: name, SSH: mergeSSH(c.SSH, host.Config)})
+		}
+	}
+
+	if hosts == "" {
+		return targets, nil
+	}
+	wanted := strings.Split(hosts, ",")
+	selected := make([]Target, 0, len(wanted))
+	for _, target := range targets {
+		if slices.Contains(wanted, target.Name) || slices.Contains(wanted, target.SSH.Host) {
+			selected = append(selected, target)
+		}
+	}
+	if len(selected) != len(wanted) {
+		return nil, fmt.Errorf("host selection %q does not match the hosts of the environment", hosts)
+	}
+	return selected, nil
+}
+
+// Rollout returns the rollout policy of an environment. Without environments
+// every host is deployed at once.
+func (c Config) Rollout(env string) (domain.RolloutPolicy, error) {
+	policy := domain.RolloutPolicy{RollbackOnHalt: true}
+	if len(c.Environments) == 0 {
+		return policy, nil
+	}
+	environment, err := c.environment(env)
+	if err != nil {
+		return policy, err
+	}
+	rollout := environment.Rollout
+	policy.BatchSize, policy.BatchPercent, err = parseBatch(rollout.Batch)
+	if err != nil {
+		return policy, err
+	}
+	policy.Pause = rollout.Pause
+	policy.MaxFailures = rollout.MaxFailures
+	if rollout.RollbackOnHalt != nil {
+		policy.RollbackOnHalt = *rollout.RollbackOnHalt
+	}
+	return policy, nil
+}
+
+// environment resolves the selected environment, falling back to the default
+// environment or to the only one declared.
+func (c Config) environment(env string) (Environment, error) {
+	if env == "" {
+		env = c.DefaultEnvironment
+	}
+	if env == "" && len(c.Environments) == 1 {
+		for name := range c.Environments {
+			env = name
+		}
+	}
+	if env == "" {
+		return Environment{}, fmt.Errorf("several environments are declared; select one with -env")
+	}
+	environment, ok := c.Environments[env]
+	if !ok {
+		return Environment{}, fmt.Errorf("environment %q is not declared", env)
+	}
+	return environment, nil
+}
+
+// parseBatch parses a batch size given as a host count or a percentage.
+func parseBatch(value string) (size, percent int, err error) {
+	if value == "" {
+		return 0, 0, nil
+	}
+	if p, ok := strings.CutSuffix(value, "%"); ok {
+		percent, err = strconv.Atoi(p)
+		if err != nil || percent <= 0 || percent > 100 {
+			return 0, 0, fmt.Errorf("invalid batch percentage %q", value)
+		}
+		return 0, percent, nil
+	}
+	size, err = strconv.Atoi(value)
+	if err != nil || size <= 0 {
+		return 0, 0, fmt.Errorf("invalid batch size %q", value)
+	}
+	return size, 0, nil
+}
+
+// mergeSSH fills unset host fields from the defaults.
+func mergeSSH(defaults, host ssh.Config) ssh.Config {
+	merged := host
+	if merged.User == "" {
+		merged.User = defaults.User
+	}
+	if merged.Port == 0 {
+		merged.Port = defaults.Port
+	}
+	if merged.Password == "" {
+		merged.Password = defaults.Password
+	}
+	if merged.PrivateKeyPath == "" {
+		merged.PrivateKeyPath = defaults.PrivateKeyPath
+	}
+	if len(merged.PrivateKeyPaths) == 0 {
+		merged.PrivateKeyPaths = defaults.PrivateKeyPaths
+	}
+	if merged.PassphraseEnv == "" {
+		merged.PassphraseEnv = defaults.PassphraseEnv
+	}
+	if merged.Agent == nil {
+		merged.Agent = defaults.Agent
+	}
+	if merged.KnownHostsPath == "" {
+		merged.KnownHostsPath = defaults.KnownHostsPath
+	}
+	if merged.ProxyJump == "" {
+		merged.ProxyJump = defaults.ProxyJump
+	}
+	if merged.ConfigFile == "" {
+		merged.ConfigFile = defaults.ConfigFile
+	}
+	if merged.KeepAlive == 0 {
+		merged.KeepAlive = defaults.KeepAlive
+	}
+	if merged.CommandTimeout == 0 {
+		merged.CommandTimeout = defaults.CommandTimeout
+	}
+	return merged
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dadyutenga/git-engine/internal/domain"
+	"github.com/dadyutenga/git-engine/internal/infrastructure/logger"
+)
+
+// handleHistory lists the deployment history ledger of a project, newest
+// entries last, as a table or as JSON lines.
+func (c CLI) handleHistory(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	tf := addTargetFlags(fs)
+	limit := fs.Int("n", 20, "number of entries to show (0 for all)")
+	operation := fs.String("operation", "", "only show push or rollback entries")
+	outcome := fs.String("outcome", "", "only show entries with this outcome (success, failed, rolled_back)")
+	user := fs.String("user", "", "only show entries started by this user")
+	since := fs.String("since", "", "only show entries newer than a duration (72h) or a date (2006-01-02)")
+	asJSON := fs.Bool("json", false, "print entries as JSON lines")
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		return fmt.Errorf("project name required")
+	}
+	project, err := c.resolveProject(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	filter := domain.HistoryFilter{Operation: *operation, Outcome: *outcome, User: *user, Limit: *limit}
+	if *since != "" {
+		if filter.Since, err = parseSince(*since, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	return c.forEachTarget(tf, func(target Target, log logger.Logger, session Session) error {
+		entries, err := session.History.List(ctx, project, filter)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			for _, entry := range entries {
+				if err := enc.Encode(struct {
+					Host string `json:"host,omitempty"`
+					domain.HistoryEntry
+				}{target.Name, entry}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		log.Info("%d history entries for %s", len(entries), project.Name)
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "STARTED\tID\tOPERATION\tBY\tREVISION\tRELEASE\tSTRATEGY\tDURATION\tOUTCOME")
+		for _, e := range entries {
+			revision := e.Ref
+			if e.Commit != "" {
+				revision = fmt.Sprintf("%s (%.7s)", e.Ref, e.Commit)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s@%s\t%s\t%s\t%s\t%s\t%s\n",
+				e.StartedAt.Local().Format(time.DateTime), e.ID, e.Operation, e.User, e.Hostname,
+				orDash(revision), orDash(e.Release), orDash(e.Strategy), e.Duration().Round(100*time.Millisecond), e.Outcome)
+		}
+		return w.Flush()
+	})
+}
+
+// parseSince accepts a duration relative to now or a calendar date.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid -since %q: use a duration such as 72h or a date such as 2006-01-02", value)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
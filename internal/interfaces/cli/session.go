@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/dadyutenga/git-engine/internal/application"
+	"github.com/dadyutenga/git-engine/internal/infrastructure/remote"
+)
+
+// Session bundles the application services bound to one remote connection.
+type Session struct {
+	Init     application.InitService
+	Deploy   application.DeployService
+	Rollback application.RollbackService
+	Status   application.StatusService
+	Logs     application.LogsService
+	History  application.HistoryService
+	Backups  application.BackupService
+	// Plan collects the steps of a dry run; it is nil for real executions.
+	Plan  *remote.Plan
+	Close func() error
+}
+
+// ConnectOptions controls how a session is opened.
+type ConnectOptions struct {
+	// DryRun records mutating commands instead of executing them.
+	DryRun bool
+	// SkipPush deploys what the bare repository already holds instead of
+	// pushing the local repository first.
+	SkipPush bool
+}
+
+// Connector opens a session against a target host.
+type Connector func(target Target, opts ConnectOptions) (Session, error)
+
+// AgentConnector opens a session bound to the machine the CLI runs on, used
+// by "deploy agent" on the server itself.
+type AgentConnector func() (Session, error)
+
+// sessionConnector adapts a Connector to application.HostConnector and keeps
+// the opened sessions so their dry-run plans can be printed afterwards.
+type sessionConnector struct {
+	connect  Connector
+	targets  map[string]Target
+	opts     ConnectOptions
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+func newSessionConnector(connect Connector, targets []Target, opts ConnectOptions) *sessionConnector {
+	byName := make(map[string]Target, len(targets))
+	for _, t := range targets {
+		byName[t.Name] = t
+	}
+	return &sessionConnector{connect: connect, targets: byName, opts: opts, sessions: map[string]Session{}}
+}
+
+// Connect opens the session of the named host.
+func (s *sessionConnector) Connect(host string) (application.HostServices, error) {
+	session, err := s.connect(s.targets[host], s.opts)
+	if err != nil {
+		return application.HostServices{}, err
+	}
+	s.mu.Lock()
+	s.sessions[host] = session
+	s.mu.Unlock()
+	return application.HostServices{Deploy: session.Deploy, Rollback: session.Rollback, Close: session.Close}, nil
+}
+
+func (s *sessionConnector) session(host string) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[host]
+	return session, ok
+}
+
+var _ application.HostConnector = (*sessionConnector)(nil)
+
+// prefixWriter prefixes every complete line with a host label. Writers
+// sharing the same mutex can be used concurrently on one destination.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	dst    io.Writer
+	prefix []byte
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			return len(p), nil
+		}
+		line := w.buf[:idx+1]
+		w.mu.Lock()
+		_, err := w.dst.Write(append(append([]byte{}, w.prefix...), line...))
+		w.mu.Unlock()
+		w.buf = w.buf[idx+1:]
+		if err != nil {
+			return len(p), err
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package shell
+
+import "fmt"
+
+// ChecksumMismatchStatus is the exit status of a VerifiedUpload command whose
+// upload does not match its checksum.
+const ChecksumMismatchStatus = 3
+
+// VerifiedUpload returns a command that runs then once the file at path
+// matches the SHA-256 digest sum and exits with ChecksumMismatchStatus
+// otherwise. The file is removed afterwards either way.
+func VerifiedUpload(sum, path, then string) string {
+	return fmt.Sprintf("if echo %s | sha256sum -c --status; then %s; else (exit %d); fi; status=$?; rm -f %s; exit $status",
+		Escape(sum+"  "+path), then, ChecksumMismatchStatus, Escape(path))
+}